@@ -0,0 +1,222 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/homedir"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/identity"
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+)
+
+const (
+	// historyConfigMapName is the name of the config map holding the audit records.
+	historyConfigMapName = "history"
+
+	// maxRecords is the number of records kept in the config map, so it does not grow over the size limit.
+	maxRecords = 500
+)
+
+// Record describes a single change the plugin made to the cluster.
+type Record struct {
+	Timestamp time.Time         `json:"timestamp"`
+	User      string            `json:"user"`
+	Command   string            `json:"command"`
+	Operator  string            `json:"operator"`
+	Namespace string            `json:"namespace,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	OldImage  string            `json:"oldImage,omitempty"`
+	NewImage  string            `json:"newImage,omitempty"`
+	Flags     map[string]string `json:"flags,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// key returns the config map key for the record, which sorts by the record time.
+func (r Record) key() string {
+	return fmt.Sprintf("%020d-%s", r.Timestamp.UnixNano(), r.Operator)
+}
+
+// LocalLogPath returns the path to the local audit log.
+func LocalLogPath() string {
+	return filepath.Join(homedir.HomeDir(), ".kube", "operator-dev", "history.log")
+}
+
+// Append stores the record in the local log and in the cluster history config map. The local log is written first, so
+// the record is kept even when the cluster can not be updated.
+func Append(client kubernetes.Interface, record Record) error {
+	localErr := appendLocal(LocalLogPath(), record)
+	if err := appendCluster(client, record); err != nil {
+		if localErr != nil {
+			return fmt.Errorf("%v (local audit log: %v)", err, localErr)
+		}
+		return err
+	}
+	if localErr != nil {
+		return fmt.Errorf("unable to write local audit log: %v", localErr)
+	}
+	return nil
+}
+
+// ChangedFlags returns the flags set on the command line, so every option of the change is recorded. The kubeconfig
+// flags are left out, as they can carry credentials (eg. --token).
+func ChangedFlags(flags *pflag.FlagSet) map[string]string {
+	kubeConfigFlags := pflag.NewFlagSet("kubeconfig", pflag.ContinueOnError)
+	genericclioptions.NewConfigFlags(true).AddFlags(kubeConfigFlags)
+	changed := map[string]string{}
+	if flags == nil {
+		return changed
+	}
+	flags.Visit(func(flag *pflag.Flag) {
+		if kubeConfigFlags.Lookup(flag.Name) == nil {
+			changed[flag.Name] = flag.Value.String()
+		}
+	})
+	return changed
+}
+
+// Log records the change made by a command, the command sets only its specific fields. The timestamp, the error and
+// the user, when not known by the command, are filled in and the empty or false flags are dropped. Failures are only
+// reported as a warning, as the change already happened.
+func Log(kubeClient kubernetes.Interface, dynamicClient dynamic.Interface, errOut io.Writer, record Record, runErr error) {
+	record.Timestamp = time.Now().UTC()
+	if len(record.User) == 0 {
+		user, err := identity.CurrentUser(dynamicClient)
+		if err != nil {
+			user = "unknown"
+		}
+		record.User = user
+	}
+	for flag, value := range record.Flags {
+		if len(value) == 0 || value == "false" {
+			delete(record.Flags, flag)
+		}
+	}
+	if runErr != nil {
+		record.Error = runErr.Error()
+	}
+	if err := Append(kubeClient, record); err != nil {
+		fmt.Fprintf(errOut, "warning: unable to record the change in the audit log: %v\n", err)
+	}
+}
+
+func appendCluster(client kubernetes.Interface, record Record) error {
+	if err := operator.EnsurePluginNamespace(client); err != nil {
+		return err
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		configMap, err := client.CoreV1().ConfigMaps(operator.PluginNamespace).Get(historyConfigMapName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = client.CoreV1().ConfigMaps(operator.PluginNamespace).Create(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: historyConfigMapName, Namespace: operator.PluginNamespace},
+				Data:       map[string]string{record.key(): string(value)},
+			})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[record.key()] = string(value)
+		pruneRecords(configMap.Data, maxRecords)
+		_, err = client.CoreV1().ConfigMaps(operator.PluginNamespace).Update(configMap)
+		return err
+	})
+}
+
+// pruneRecords removes the oldest records so at most limit records are kept.
+func pruneRecords(data map[string]string, limit int) {
+	if len(data) <= limit {
+		return
+	}
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys[:len(keys)-limit] {
+		delete(data, k)
+	}
+}
+
+func appendLocal(path string, record Record) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(record)
+}
+
+// List returns the records stored in the cluster, oldest first.
+func List(client kubernetes.Interface) ([]Record, error) {
+	configMap, err := client.CoreV1().ConfigMaps(operator.PluginNamespace).Get(historyConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeRecords(configMap.Data), nil
+}
+
+// ListLocal returns the records stored in the local log, oldest first.
+func ListLocal() ([]Record, error) {
+	f, err := os.Open(LocalLogPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		record := Record{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue // ignore
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+func decodeRecords(data map[string]string) []Record {
+	var records []Record
+	for _, value := range data {
+		record := Record{}
+		if err := json.Unmarshal([]byte(value), &record); err != nil {
+			continue // ignore
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	return records
+}
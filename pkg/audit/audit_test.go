@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func Test_pruneRecords(t *testing.T) {
+	now := time.Now()
+	data := map[string]string{}
+	for i := 0; i < 5; i++ {
+		record := Record{Timestamp: now.Add(time.Duration(i) * time.Minute), Operator: "kube-apiserver"}
+		value, err := json.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[record.key()] = string(value)
+	}
+
+	pruneRecords(data, 3)
+
+	records := decodeRecords(data)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	if !records[0].Timestamp.Equal(now.Add(2 * time.Minute)) {
+		t.Errorf("expected the oldest records to be pruned, first record is from %s", records[0].Timestamp)
+	}
+	for i := 1; i < len(records); i++ {
+		if records[i].Timestamp.Before(records[i-1].Timestamp) {
+			t.Errorf("expected records to be sorted by time")
+		}
+	}
+}
+
+func TestChangedFlags(t *testing.T) {
+	flags := pflag.NewFlagSet("override", pflag.ContinueOnError)
+	flags.String("image", "", "")
+	flags.Bool("force", false, "")
+	flags.Duration("ttl", 0, "")
+	flags.String("pull-secret", "", "")
+	genericclioptions.NewConfigFlags(true).AddFlags(flags)
+	if err := flags.Parse([]string{"--image=foo", "--force", "--ttl=4h", "--token=secret", "-n", "openshift-foo"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"image": "foo", "force": "true", "ttl": "4h0m0s"}
+	if changed := ChangedFlags(flags); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("expected %v, got %v", expected, changed)
+	}
+}
@@ -0,0 +1,123 @@
+package assert
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+)
+
+// AssertOptions provides information required to verify the
+// conditions of a cluster operator
+type AssertOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	args       []string
+	conditions string
+	stable     time.Duration
+	timeout    time.Duration
+
+	expectations []operator.ConditionExpectation
+
+	dynamicClient dynamic.Interface
+
+	genericclioptions.IOStreams
+}
+
+// NewAssertOptions provides an instance of AssertOptions with default values
+func NewAssertOptions(streams genericclioptions.IOStreams) *AssertOptions {
+	return &AssertOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		conditions:  "Available=True,Degraded=False",
+		stable:      30 * time.Second,
+		timeout:     10 * time.Minute,
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	operatorAssertExample = `
+	# wait until the kube-apiserver operator is available and not degraded for 1 minute
+	%[1]s kube-apiserver --conditions=Available=True,Degraded=False --stable=1m
+
+    # fail when the operator does not settle within 5 minutes
+	%[1]s kube-apiserver --conditions=Available=True,Progressing=False,Degraded=False --timeout=5m
+`
+)
+
+func NewCmdOperatorAssert(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewAssertOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "assert <clusteroperator/name>",
+		Short:   "Assert the cluster operator conditions hold for a period of time",
+		Example: fmt.Sprintf(operatorAssertExample, "oc operator-dev assert"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.conditions, "conditions", o.conditions, "comma separated list of expected conditions (eg. Available=True,Degraded=False)")
+	cmd.Flags().DurationVar(&o.stable, "stable", o.stable, "how long the conditions must hold")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", o.timeout, "how long to wait for the conditions to become stable")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *AssertOptions) Validate() error {
+	if len(o.args) == 0 {
+		return fmt.Errorf("clusteroperator/name must be specified")
+	}
+	if o.stable > o.timeout {
+		return fmt.Errorf("--stable must not be longer than --timeout")
+	}
+	expectations, err := operator.ParseConditionExpectations(o.conditions)
+	if err != nil {
+		return err
+	}
+	o.expectations = expectations
+	return nil
+}
+
+func (o *AssertOptions) printOut(message string, objs ...interface{}) {
+	if _, err := fmt.Fprintf(o.Out, message, objs...); err != nil {
+		panic(err)
+	}
+}
+
+func (o *AssertOptions) Complete() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.dynamicClient = dynamicClient
+
+	return nil
+}
+
+func (o *AssertOptions) Run() error {
+	o.printOut("-> Waiting for operator %q conditions %s to hold for %s ...\n", o.args[0], o.conditions, o.stable)
+	if err := operator.WaitForConditions(o.dynamicClient, o.args[0], o.expectations, o.stable, o.timeout); err != nil {
+		return err
+	}
+	o.printOut("-> Operator %q conditions are stable\n", o.args[0])
+	return nil
+}
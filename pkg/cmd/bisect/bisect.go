@@ -0,0 +1,373 @@
+package bisect
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/audit"
+	"github.com/mfojtik/operator-dev-plugin/pkg/identity"
+	"github.com/mfojtik/operator-dev-plugin/pkg/lock"
+	"github.com/mfojtik/operator-dev-plugin/pkg/olm"
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+	"github.com/mfojtik/operator-dev-plugin/pkg/transaction"
+)
+
+// BisectOptions provides information required to find
+// the first bad operator image
+type BisectOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	flags       *pflag.FlagSet
+
+	args         []string
+	good         string
+	bad          string
+	imagesFile   string
+	test         string
+	deployment   string
+	force        bool
+	stable       time.Duration
+	timeout      time.Duration
+	images       []string
+	user         string
+	expectations []operator.ConditionExpectation
+
+	dynamicClient dynamic.Interface
+	kubeClient    kubernetes.Interface
+
+	genericclioptions.IOStreams
+}
+
+// NewBisectOptions provides an instance of BisectOptions with default values
+func NewBisectOptions(streams genericclioptions.IOStreams) *BisectOptions {
+	return &BisectOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		stable:      30 * time.Second,
+		timeout:     10 * time.Minute,
+		expectations: []operator.ConditionExpectation{
+			{Type: "Available", Status: "True"},
+			{Type: "Degraded", Status: "False"},
+		},
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	operatorBisectExample = `
+	# find the first kube-apiserver operator image that breaks the test script.
+	# The images.txt file contains one image per line, ordered from the oldest to the newest build.
+	# The test script gets the tested image in IMAGE environment variable, exit code 0 means the image is good.
+	%[1]s kube-apiserver --good=quay.io/foo/operator:1 --bad=quay.io/foo/operator:9 --images=images.txt --test="./hack/test.sh"
+`
+)
+
+func NewCmdOperatorBisect(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewBisectOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "bisect <clusteroperator/name>",
+		Short:   "Find the first operator image that fails the test",
+		Example: fmt.Sprintf(operatorBisectExample, "oc operator-dev bisect"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			o.flags = c.Flags()
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.good, "good", o.good, "known good image (default is the first image in the list)")
+	cmd.Flags().StringVar(&o.bad, "bad", o.bad, "known bad image (default is the last image in the list)")
+	cmd.Flags().StringVar(&o.imagesFile, "images", o.imagesFile, "file with the images to bisect, one per line, ordered from the oldest")
+	cmd.Flags().StringVar(&o.test, "test", o.test, "shell command testing the image, exit code 0 means the image is good")
+	cmd.Flags().StringVar(&o.deployment, "deployment", o.deployment, "custom deployment name")
+	cmd.Flags().BoolVar(&o.force, "force", o.force, "take over the operator lock even when it is held by another user")
+	cmd.Flags().DurationVar(&o.stable, "stable", o.stable, "how long the operator must be Available and not Degraded before the test runs")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", o.timeout, "how long to wait for the operator to become stable with each image")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// readImages reads the image list, ignoring empty lines and comments.
+func readImages(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var images []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		images = append(images, line)
+	}
+	return images, scanner.Err()
+}
+
+// bounds returns the index of the good and bad image in the list.
+func bounds(images []string, good, bad string) (int, int, error) {
+	goodIndex, badIndex := 0, len(images)-1
+	for i, image := range images {
+		if image == good {
+			goodIndex = i
+		}
+		if image == bad {
+			badIndex = i
+		}
+	}
+	if len(good) > 0 && images[goodIndex] != good {
+		return 0, 0, fmt.Errorf("good image %q is not in the image list", good)
+	}
+	if len(bad) > 0 && images[badIndex] != bad {
+		return 0, 0, fmt.Errorf("bad image %q is not in the image list", bad)
+	}
+	if goodIndex >= badIndex {
+		return 0, 0, fmt.Errorf("good image must be listed before the bad image")
+	}
+	return goodIndex, badIndex, nil
+}
+
+// bisect returns the index of the first bad image between the good and bad index, using the test to check the images.
+func bisect(good, bad int, test func(int) (bool, error)) (int, error) {
+	for bad-good > 1 {
+		mid := good + (bad-good)/2
+		ok, err := test(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			good = mid
+		} else {
+			bad = mid
+		}
+	}
+	return bad, nil
+}
+
+func (o *BisectOptions) Validate() error {
+	if len(o.args) == 0 {
+		return fmt.Errorf("clusteroperator/name must be specified")
+	}
+	if len(o.imagesFile) == 0 {
+		return fmt.Errorf("--images must be specified")
+	}
+	if len(o.test) == 0 {
+		return fmt.Errorf("--test must be specified")
+	}
+	images, err := readImages(o.imagesFile)
+	if err != nil {
+		return fmt.Errorf("unable to read images: %v", err)
+	}
+	if len(images) < 2 {
+		return fmt.Errorf("at least two images are needed to bisect")
+	}
+	o.images = images
+	_, _, err = bounds(o.images, o.good, o.bad)
+	return err
+}
+
+func (o *BisectOptions) printOut(message string, objs ...interface{}) {
+	if _, err := fmt.Fprintf(o.Out, message, objs...); err != nil {
+		panic(err)
+	}
+}
+
+func (o *BisectOptions) Complete() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.dynamicClient = dynamicClient
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.kubeClient = kubeClient
+
+	return nil
+}
+
+func (o *BisectOptions) Run() (err error) {
+	if _, err := o.dynamicClient.Resource(operator.ClusterOperatorGVR).Get(o.args[0], metav1.GetOptions{}); err != nil {
+		return fmt.Errorf("operator %q is not valid operator: %v", o.args[0], err)
+	}
+	deployment, err := operator.ResolveDeployment(o.kubeClient, o.args[0], o.deployment)
+	if err != nil {
+		return err
+	}
+	deploymentNS, deploymentName := deployment.Namespace, deployment.Name
+
+	// OLM reverts every image set in the deployment, the images would never be tested
+	csv, err := olm.OwningCSV(o.dynamicClient, deployment)
+	if err != nil {
+		return err
+	}
+	if csv != nil {
+		return fmt.Errorf("bisect can not be used for operator installed by OLM clusterserviceversion %s/%s", csv.GetNamespace(), csv.GetName())
+	}
+
+	if o.user, err = identity.CurrentUser(o.dynamicClient); err != nil {
+		return err
+	}
+
+	// clusters without the cluster version operator (eg. kind) do not need the clusterversion override
+	clusterVersion, err := operator.HasClusterVersion(o.dynamicClient)
+	if err != nil {
+		return err
+	}
+
+	// every bisect step changes the cluster, the original state is restored at the end in any case
+	ctx, cancel := transaction.WithSignals(context.Background())
+	defer cancel()
+	tx := transaction.New(o.Out)
+	defer func() {
+		if tx.Empty() {
+			return
+		}
+		o.printOut("-> Restoring operator %q ...\n", o.args[0])
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && err == nil {
+			err = rollbackErr
+		}
+	}()
+
+	// the same lock and clusterversion override as the override takes, so reap and history know the operator is locked
+	if err := tx.Run(ctx, lock.Step(o.kubeClient, o.args[0], lock.Options{
+		Holder:    o.user,
+		Duration:  lock.DefaultDuration,
+		Force:     o.force,
+		Kind:      operator.DeploymentKind,
+		Namespace: deploymentNS,
+		Name:      deploymentName,
+	})); err != nil {
+		return err
+	}
+	if clusterVersion {
+		if err := tx.Run(ctx, operator.UnmanagedStep(o.dynamicClient, operator.DeploymentOverride(deploymentNS, deploymentName))); err != nil {
+			return err
+		}
+	}
+
+	// every tested image changes the deployment, the original pod template is restored at the end
+	originalTemplate := deployment.Spec.Template.DeepCopy()
+	if err := tx.Run(ctx, transaction.Step{
+		Name: "operator deployment",
+		Do: func(ctx context.Context) error {
+			return nil
+		},
+		Undo: func() error {
+			_, err := operator.UpdatePodTemplate(o.kubeClient, operator.DeploymentKind, deploymentNS, deploymentName, func(template *corev1.PodTemplateSpec) error {
+				*template = *originalTemplate
+				return nil
+			})
+			return err
+		},
+	}); err != nil {
+		return err
+	}
+
+	good, bad, _ := bounds(o.images, o.good, o.bad)
+	steps := 0
+	firstBad, err := bisect(good, bad, func(i int) (bool, error) {
+		steps++
+		image := o.images[i]
+		o.printOut("-> [%d] Testing image %q (%d images left between good and bad) ...\n", steps, image, bad-good-1)
+		ok, err := o.testImage(ctx, deploymentNS, deploymentName, image)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			good = i
+			o.printOut("-> Image %q is good\n", image)
+		} else {
+			bad = i
+			o.printOut("-> Image %q is bad\n", image)
+		}
+		return ok, nil
+	})
+	o.recordAudit(deploymentNS, deploymentName, firstBad, err)
+	if err != nil {
+		return err
+	}
+	o.printOut("-> First bad image is %q (last good image is %q)\n", o.images[firstBad], o.images[firstBad-1])
+	return nil
+}
+
+// testImage overrides the operator image, waits for the operator to become stable and runs the test.
+// The operator that does not become stable with the image is considered bad.
+func (o *BisectOptions) testImage(ctx context.Context, namespace, name, image string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, fmt.Errorf("interrupted")
+	}
+	if _, err := operator.UpdatePodTemplate(o.kubeClient, operator.DeploymentKind, namespace, name, func(template *corev1.PodTemplateSpec) error {
+		operator.SetOperatorImage(&template.Spec, image)
+		return nil
+	}); err != nil {
+		return false, fmt.Errorf("unable to update deployment %s/%s: %v", namespace, name, err)
+	}
+	if err := operator.WaitForDeploymentRollout(o.kubeClient, namespace, name, o.timeout); err != nil {
+		o.printOut("   %v\n", err)
+		return false, nil
+	}
+	if err := operator.WaitForConditions(o.dynamicClient, o.args[0], o.expectations, o.stable, o.timeout); err != nil {
+		o.printOut("   %v\n", err)
+		return false, nil
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", o.test)
+	cmd.Env = append(os.Environ(), "IMAGE="+image)
+	cmd.Stdout, cmd.Stderr = o.Out, o.ErrOut
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return false, fmt.Errorf("interrupted")
+	}
+	if _, failed := err.(*exec.ExitError); failed {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to run test: %v", err)
+	}
+	return true, nil
+}
+
+func (o *BisectOptions) recordAudit(namespace, name string, firstBad int, runErr error) {
+	record := audit.Record{
+		User:      o.user,
+		Command:   "bisect",
+		Operator:  o.args[0],
+		Namespace: namespace,
+		Name:      name,
+		Flags:     audit.ChangedFlags(o.flags),
+	}
+	if runErr == nil {
+		record.NewImage = o.images[firstBad]
+	}
+	audit.Log(o.kubeClient, o.dynamicClient, o.ErrOut, record, runErr)
+}
@@ -0,0 +1,52 @@
+package bisect
+
+import (
+	"testing"
+)
+
+func Test_bisect(t *testing.T) {
+	tests := []struct {
+		name      string
+		good, bad int
+		firstBad  int
+	}{
+		{name: "adjacent", good: 0, bad: 1, firstBad: 1},
+		{name: "first after good", good: 0, bad: 9, firstBad: 1},
+		{name: "middle", good: 0, bad: 9, firstBad: 5},
+		{name: "last", good: 2, bad: 9, firstBad: 9},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tested := 0
+			result, err := bisect(test.good, test.bad, func(i int) (bool, error) {
+				tested++
+				return i < test.firstBad, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != test.firstBad {
+				t.Errorf("expected first bad %d, got %d", test.firstBad, result)
+			}
+			if tested > 4 {
+				t.Errorf("expected at most 4 tests, got %d", tested)
+			}
+		})
+	}
+}
+
+func Test_bounds(t *testing.T) {
+	images := []string{"a", "b", "c", "d"}
+	if good, bad, err := bounds(images, "", ""); err != nil || good != 0 || bad != 3 {
+		t.Errorf("expected default bounds 0 and 3, got %d, %d, %v", good, bad, err)
+	}
+	if good, bad, err := bounds(images, "b", "c"); err != nil || good != 1 || bad != 2 {
+		t.Errorf("expected bounds 1 and 2, got %d, %d, %v", good, bad, err)
+	}
+	if _, _, err := bounds(images, "c", "b"); err == nil {
+		t.Errorf("expected error when good image is after bad image")
+	}
+	if _, _, err := bounds(images, "x", ""); err == nil {
+		t.Errorf("expected error for unknown image")
+	}
+}
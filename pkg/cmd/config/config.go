@@ -0,0 +1,388 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/audit"
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+)
+
+// undoAnnotation holds the original values of the fields changed by this command, so they can be restored.
+const undoAnnotation = "operator-dev.openshift.io/config-undo"
+
+var validManagementStates = []string{"Managed", "Unmanaged", "Removed", "Force"}
+
+// ConfigOptions provides information required to change
+// the configuration of an operator
+type ConfigOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	flags       *pflag.FlagSet
+
+	args  []string
+	set   []string
+	state string
+	undo  bool
+
+	assignments []assignment
+
+	dynamicClient dynamic.Interface
+	kubeClient    kubernetes.Interface
+	mapper        meta.RESTMapper
+
+	genericclioptions.IOStreams
+}
+
+// NewConfigOptions provides an instance of ConfigOptions with default values
+func NewConfigOptions(streams genericclioptions.IOStreams) *ConfigOptions {
+	return &ConfigOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	operatorConfigExample = `
+	# set the unsupported config overrides of the kube-apiserver operator (paths are relative to spec.unsupportedConfigOverrides)
+	%[1]s kube-apiserver --set apiServerArguments.shutdown-delay-duration='["10s"]' --set foo.enabled=true
+
+    # stop the operator from managing its operand
+	%[1]s kube-apiserver --state Unmanaged
+
+    # restore all values changed by this command
+	%[1]s kube-apiserver --undo
+`
+)
+
+func NewCmdOperatorConfig(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewConfigOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "config <clusteroperator/name>",
+		Short:   "Change the unsupported config overrides and management state of the operator",
+		Example: fmt.Sprintf(operatorConfigExample, "oc operator-dev config"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			o.flags = c.Flags()
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&o.set, "set", o.set, "path.to.key=value to set in spec.unsupportedConfigOverrides (value is parsed as JSON when possible)")
+	cmd.Flags().StringVar(&o.state, "state", o.state, "management state of the operator ("+strings.Join(validManagementStates, ", ")+")")
+	cmd.Flags().BoolVar(&o.undo, "undo", o.undo, "restore the values changed by this command")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// assignment is a single value to set in the unsupported config overrides.
+type assignment struct {
+	path  string
+	value interface{}
+}
+
+func (a assignment) fields() []string {
+	return append([]string{"spec", "unsupportedConfigOverrides"}, strings.Split(a.path, ".")...)
+}
+
+// parseAssignment parses the path.to.key=value. The value is parsed as JSON when possible, as string otherwise.
+func parseAssignment(value string) (assignment, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return assignment{}, fmt.Errorf("invalid value %q, must be in path.to.key=value format", value)
+	}
+	for _, field := range strings.Split(parts[0], ".") {
+		if len(field) == 0 {
+			return assignment{}, fmt.Errorf("invalid path %q", parts[0])
+		}
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(parts[1]), &parsed); err != nil {
+		parsed = parts[1]
+	}
+	return assignment{path: parts[0], value: normalizeNumbers(parsed)}, nil
+}
+
+// normalizeNumbers converts the whole JSON numbers to int64, so they are stored as integers.
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt64 {
+			return int64(v)
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = normalizeNumbers(v[k])
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeNumbers(v[i])
+		}
+	}
+	return value
+}
+
+func (o *ConfigOptions) Validate() error {
+	if len(o.args) == 0 {
+		return fmt.Errorf("clusteroperator/name must be specified")
+	}
+	if o.undo && (len(o.set) > 0 || len(o.state) > 0) {
+		return fmt.Errorf("--undo can not be combined with --set or --state")
+	}
+	if !o.undo && len(o.set) == 0 && len(o.state) == 0 {
+		return fmt.Errorf("at least one of --set, --state or --undo must be specified")
+	}
+	if len(o.state) > 0 {
+		valid := false
+		for _, state := range validManagementStates {
+			if strings.EqualFold(o.state, state) {
+				o.state, valid = state, true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid management state %q, must be one of %s", o.state, strings.Join(validManagementStates, ", "))
+		}
+	}
+	for _, value := range o.set {
+		a, err := parseAssignment(value)
+		if err != nil {
+			return err
+		}
+		o.assignments = append(o.assignments, a)
+	}
+	return nil
+}
+
+func (o *ConfigOptions) printOut(message string, objs ...interface{}) {
+	if _, err := fmt.Fprintf(o.Out, message, objs...); err != nil {
+		panic(err)
+	}
+}
+
+func (o *ConfigOptions) Complete() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.dynamicClient = dynamicClient
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.kubeClient = kubeClient
+
+	mapper, err := o.configFlags.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+	o.mapper = mapper
+
+	return nil
+}
+
+// undoRecord holds the original values of the fields changed by this command.
+type undoRecord struct {
+	// ManagementState is the original management state, nil when it was not changed
+	ManagementState *string `json:"managementState,omitempty"`
+	// Overrides are the original unsupportedConfigOverrides, nil when they were not changed. The whole field is restored,
+	// so the parent maps created by --set do not stay behind.
+	Overrides *originalField `json:"overrides,omitempty"`
+}
+
+// originalField is the original value of a field, found is false when the field did not exist.
+type originalField struct {
+	Found bool        `json:"found"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+func readUndoRecord(obj *unstructured.Unstructured) (*undoRecord, error) {
+	value, ok := obj.GetAnnotations()[undoAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	record := &undoRecord{}
+	if err := json.Unmarshal([]byte(value), record); err != nil {
+		return nil, fmt.Errorf("unable to decode %s annotation: %v", undoAnnotation, err)
+	}
+	return record, nil
+}
+
+func writeUndoRecord(obj *unstructured.Unstructured, record *undoRecord) error {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if record == nil {
+		delete(annotations, undoAnnotation)
+		obj.SetAnnotations(annotations)
+		return nil
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	annotations[undoAnnotation] = string(value)
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// apply changes the operator resource and records the original values, unless they were recorded by previous run.
+func (o *ConfigOptions) apply(obj *unstructured.Unstructured) error {
+	record, err := readUndoRecord(obj)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		record = &undoRecord{}
+	}
+
+	if len(o.assignments) > 0 && record.Overrides == nil {
+		original, found, _ := unstructured.NestedFieldCopy(obj.Object, "spec", "unsupportedConfigOverrides")
+		record.Overrides = &originalField{Found: found, Value: original}
+	}
+
+	// the operators usually have "unsupportedConfigOverrides: null" which can not be set into
+	if overrides, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "unsupportedConfigOverrides"); found && overrides == nil {
+		unstructured.RemoveNestedField(obj.Object, "spec", "unsupportedConfigOverrides")
+	}
+
+	for _, a := range o.assignments {
+		if err := unstructured.SetNestedField(obj.Object, a.value, a.fields()...); err != nil {
+			return fmt.Errorf("unable to set %s: %v", a.path, err)
+		}
+	}
+
+	if len(o.state) > 0 {
+		if record.ManagementState == nil {
+			original, _, _ := unstructured.NestedString(obj.Object, "spec", "managementState")
+			record.ManagementState = &original
+		}
+		if err := unstructured.SetNestedField(obj.Object, o.state, "spec", "managementState"); err != nil {
+			return err
+		}
+	}
+
+	return writeUndoRecord(obj, record)
+}
+
+// restore sets the values recorded in the undo annotation back and removes the annotation.
+func restore(obj *unstructured.Unstructured) error {
+	record, err := readUndoRecord(obj)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return fmt.Errorf("%s/%s has no changes to undo", strings.ToLower(obj.GetKind()), obj.GetName())
+	}
+
+	if record.Overrides != nil {
+		if !record.Overrides.Found {
+			unstructured.RemoveNestedField(obj.Object, "spec", "unsupportedConfigOverrides")
+		} else if err := unstructured.SetNestedField(obj.Object, normalizeNumbers(record.Overrides.Value), "spec", "unsupportedConfigOverrides"); err != nil {
+			return fmt.Errorf("unable to restore unsupportedConfigOverrides: %v", err)
+		}
+	}
+	if record.ManagementState != nil {
+		if len(*record.ManagementState) == 0 {
+			unstructured.RemoveNestedField(obj.Object, "spec", "managementState")
+		} else if err := unstructured.SetNestedField(obj.Object, *record.ManagementState, "spec", "managementState"); err != nil {
+			return err
+		}
+	}
+
+	return writeUndoRecord(obj, nil)
+}
+
+func (o *ConfigOptions) Run() error {
+	clusterOperator, err := o.dynamicClient.Resource(operator.ClusterOperatorGVR).Get(o.args[0], metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("operator %q is not valid operator: %v", o.args[0], err)
+	}
+	operatorConfig, err := operator.GetOperatorConfig(clusterOperator, o.mapper)
+	if err != nil {
+		return err
+	}
+
+	var before, after interface{}
+	if err := operatorConfig.Update(o.dynamicClient, func(obj *unstructured.Unstructured) error {
+		before, _, _ = unstructured.NestedFieldCopy(obj.Object, "spec")
+		mutate := o.apply
+		if o.undo {
+			mutate = restore
+		}
+		if err := mutate(obj); err != nil {
+			return err
+		}
+		after, _, _ = unstructured.NestedFieldCopy(obj.Object, "spec")
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to update %s/%s: %v", operatorConfig.GVR.Resource, operatorConfig.Name, err)
+	}
+
+	diff, err := specDiff(before, after)
+	if err != nil {
+		return err
+	}
+	if len(diff) == 0 {
+		o.printOut("-> No changes to %s/%s\n", operatorConfig.GVR.Resource, operatorConfig.Name)
+	} else {
+		o.printOut("-> Updated %s/%s:\n%s", operatorConfig.GVR.Resource, operatorConfig.Name, diff)
+	}
+
+	o.recordAudit()
+	return nil
+}
+
+// specDiff returns the unified diff of the spec in YAML format.
+func specDiff(before, after interface{}) (string, error) {
+	beforeYAML, err := yaml.Marshal(before)
+	if err != nil {
+		return "", err
+	}
+	afterYAML, err := yaml.Marshal(after)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(beforeYAML)),
+		B:        difflib.SplitLines(string(afterYAML)),
+		FromFile: "spec (before)",
+		ToFile:   "spec (after)",
+		Context:  3,
+	})
+}
+
+func (o *ConfigOptions) recordAudit() {
+	audit.Log(o.kubeClient, o.dynamicClient, o.ErrOut, audit.Record{
+		Command:  "config",
+		Operator: o.args[0],
+		Flags:    audit.ChangedFlags(o.flags),
+	}, nil)
+}
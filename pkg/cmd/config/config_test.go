@@ -0,0 +1,87 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_parseAssignment(t *testing.T) {
+	tests := map[string]struct {
+		expected    assignment
+		expectError bool
+	}{
+		"foo.enabled=true":   {expected: assignment{path: "foo.enabled", value: true}},
+		"foo.replicas=3":     {expected: assignment{path: "foo.replicas", value: int64(3)}},
+		"foo.name=bar":       {expected: assignment{path: "foo.name", value: "bar"}},
+		`foo.args=["--v=4"]`: {expected: assignment{path: "foo.args", value: []interface{}{"--v=4"}}},
+		"foo":                {expectError: true},
+		"foo..bar=1":         {expectError: true},
+	}
+
+	for value, test := range tests {
+		t.Run(value, func(t *testing.T) {
+			got, err := parseAssignment(value)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestApplyAndRestore(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "KubeAPIServer",
+		"metadata": map[string]interface{}{"name": "cluster"},
+		"spec": map[string]interface{}{
+			"managementState":            "Managed",
+			"unsupportedConfigOverrides": nil,
+		},
+	}}
+	original := obj.DeepCopy()
+
+	enabled, _ := parseAssignment("foo.enabled=true")
+	replicas, _ := parseAssignment("foo.replicas=3")
+	o := &ConfigOptions{state: "Unmanaged", assignments: []assignment{enabled}}
+	if err := o.apply(obj); err != nil {
+		t.Fatal(err)
+	}
+	// the second run must keep the values recorded by the first one
+	o = &ConfigOptions{assignments: []assignment{enabled, replicas}}
+	if err := o.apply(obj); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, _, _ := unstructured.NestedString(obj.Object, "spec", "managementState"); state != "Unmanaged" {
+		t.Errorf("expected management state to be Unmanaged, got %q", state)
+	}
+	if value, _, _ := unstructured.NestedInt64(obj.Object, "spec", "unsupportedConfigOverrides", "foo", "replicas"); value != 3 {
+		t.Errorf("expected replicas to be set, got %v", value)
+	}
+
+	if err := restore(obj); err != nil {
+		t.Fatal(err)
+	}
+	if state, _, _ := unstructured.NestedString(obj.Object, "spec", "managementState"); state != "Managed" {
+		t.Errorf("expected management state to be restored, got %q", state)
+	}
+	if overrides, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "unsupportedConfigOverrides"); !found || overrides != nil {
+		t.Errorf("expected unsupportedConfigOverrides to be null again, got %#v", obj.Object["spec"])
+	}
+	if _, ok := obj.GetAnnotations()[undoAnnotation]; ok {
+		t.Errorf("expected undo annotation to be removed")
+	}
+	if err := restore(original); err == nil {
+		t.Errorf("expected error when there is nothing to undo")
+	}
+}
@@ -0,0 +1,351 @@
+package featuregate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/audit"
+)
+
+var featureGateGVR = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "featuregates"}
+
+// renderedConfig is a config map with the operand configuration rendered by the operator from the observed config.
+type renderedConfig struct {
+	operator  string
+	namespace string
+	name      string
+}
+
+// renderedConfigs are the operands that pass the feature gates in their config.yaml.
+var renderedConfigs = []renderedConfig{
+	{operator: "kube-apiserver", namespace: "openshift-kube-apiserver", name: "config"},
+	{operator: "kube-controller-manager", namespace: "openshift-kube-controller-manager", name: "config"},
+	{operator: "kube-scheduler", namespace: "openshift-kube-scheduler", name: "config"},
+	{operator: "openshift-apiserver", namespace: "openshift-apiserver", name: "config"},
+	{operator: "openshift-controller-manager", namespace: "openshift-controller-manager", name: "config"},
+}
+
+// FeatureGateOptions provides information required to toggle
+// the cluster feature gates
+type FeatureGateOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	flags       *pflag.FlagSet
+
+	enable      []string
+	disable     []string
+	techPreview bool
+	yes         bool
+	wait        bool
+	timeout     time.Duration
+
+	dynamicClient dynamic.Interface
+	kubeClient    kubernetes.Interface
+
+	genericclioptions.IOStreams
+}
+
+// NewFeatureGateOptions provides an instance of FeatureGateOptions with default values
+func NewFeatureGateOptions(streams genericclioptions.IOStreams) *FeatureGateOptions {
+	return &FeatureGateOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		timeout:     20 * time.Minute,
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	featureGateExample = `
+	# show which operators picked up the custom feature gates
+	%[1]s
+
+    # enable and disable custom feature gates and wait until the operators pick them up.
+    # WARNING: This can not be undone and the cluster can not be upgraded anymore.
+	%[1]s --enable=MyFeature --disable=OtherFeature --yes --wait
+
+    # enable the TechPreviewNoUpgrade feature set
+	%[1]s --tech-preview --yes
+`
+)
+
+func NewCmdOperatorFeatureGate(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewFeatureGateOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "featuregate",
+		Short:   "Toggle the cluster feature gates and track which operators picked them up",
+		Example: fmt.Sprintf(featureGateExample, "oc operator-dev featuregate"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.flags = c.Flags()
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&o.enable, "enable", o.enable, "feature gates to enable (sets CustomNoUpgrade feature set)")
+	cmd.Flags().StringSliceVar(&o.disable, "disable", o.disable, "feature gates to disable (sets CustomNoUpgrade feature set)")
+	cmd.Flags().BoolVar(&o.techPreview, "tech-preview", o.techPreview, "set TechPreviewNoUpgrade feature set")
+	cmd.Flags().BoolVar(&o.yes, "yes", o.yes, "confirm the change of feature gates, which can not be undone")
+	cmd.Flags().BoolVar(&o.wait, "wait", o.wait, "wait until all operators pick up the custom feature gates")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", o.timeout, "how long to wait for the operators")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *FeatureGateOptions) changes() bool {
+	return len(o.enable) > 0 || len(o.disable) > 0 || o.techPreview
+}
+
+func (o *FeatureGateOptions) Validate() error {
+	if o.techPreview && (len(o.enable) > 0 || len(o.disable) > 0) {
+		return fmt.Errorf("--tech-preview can not be combined with --enable or --disable")
+	}
+	for _, gate := range o.enable {
+		for _, other := range o.disable {
+			if gate == other {
+				return fmt.Errorf("feature gate %q can not be both enabled and disabled", gate)
+			}
+		}
+	}
+	if o.changes() && !o.yes {
+		fmt.Fprintf(o.ErrOut, "WARNING: Changing the feature set of a cluster can not be undone and it prevents the cluster from upgrading.\n")
+		return fmt.Errorf("use --yes to confirm the change")
+	}
+	return nil
+}
+
+func (o *FeatureGateOptions) printOut(message string, objs ...interface{}) {
+	if _, err := fmt.Fprintf(o.Out, message, objs...); err != nil {
+		panic(err)
+	}
+}
+
+func (o *FeatureGateOptions) Complete() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.dynamicClient = dynamicClient
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.kubeClient = kubeClient
+
+	return nil
+}
+
+// mergeGates returns the custom enabled and disabled gates after enabling and disabling the given gates.
+func mergeGates(enabled, disabled, enable, disable []string) ([]string, []string) {
+	state := map[string]bool{}
+	for _, gate := range enabled {
+		state[gate] = true
+	}
+	for _, gate := range disabled {
+		state[gate] = false
+	}
+	for _, gate := range enable {
+		state[gate] = true
+	}
+	for _, gate := range disable {
+		state[gate] = false
+	}
+	newEnabled, newDisabled := []string{}, []string{}
+	for gate, on := range state {
+		if on {
+			newEnabled = append(newEnabled, gate)
+		} else {
+			newDisabled = append(newDisabled, gate)
+		}
+	}
+	sort.Strings(newEnabled)
+	sort.Strings(newDisabled)
+	return newEnabled, newDisabled
+}
+
+// missingGates returns the gates not present in the rendered operand config.
+func missingGates(config string, enabled, disabled []string) []string {
+	gates := renderedGates(config)
+	var missing []string
+	for _, gate := range enabled {
+		if !gates[gate+"=true"] {
+			missing = append(missing, gate+"=true")
+		}
+	}
+	for _, gate := range disabled {
+		if !gates[gate+"=false"] {
+			missing = append(missing, gate+"=false")
+		}
+	}
+	return missing
+}
+
+// renderedGates returns the name=value entries of all "feature-gates" and "featureGates" lists in the rendered config.
+// The lists hold the entries as items, comma separated strings or name: value maps.
+func renderedGates(config string) map[string]bool {
+	gates := map[string]bool{}
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(config), &value); err != nil {
+		return gates
+	}
+	var walk func(value interface{}, inGates bool)
+	walk = func(value interface{}, inGates bool) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, item := range v {
+				if inGates {
+					gates[fmt.Sprintf("%s=%v", key, item)] = true
+					continue
+				}
+				walk(item, key == "feature-gates" || key == "featureGates")
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item, inGates)
+			}
+		case string:
+			if !inGates {
+				return
+			}
+			for _, gate := range strings.Split(v, ",") {
+				gates[strings.TrimSpace(gate)] = true
+			}
+		}
+	}
+	walk(value, false)
+	return gates
+}
+
+func (o *FeatureGateOptions) Run() error {
+	var enabled, disabled []string
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		featureGate, err := o.dynamicClient.Resource(featureGateGVR).Get("cluster", metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		enabled, _, _ = unstructured.NestedStringSlice(featureGate.Object, "spec", "customNoUpgrade", "enabled")
+		disabled, _, _ = unstructured.NestedStringSlice(featureGate.Object, "spec", "customNoUpgrade", "disabled")
+		if !o.changes() {
+			return nil
+		}
+
+		if o.techPreview {
+			if err := unstructured.SetNestedField(featureGate.Object, "TechPreviewNoUpgrade", "spec", "featureSet"); err != nil {
+				return err
+			}
+		} else {
+			enabled, disabled = mergeGates(enabled, disabled, o.enable, o.disable)
+			if err := unstructured.SetNestedField(featureGate.Object, "CustomNoUpgrade", "spec", "featureSet"); err != nil {
+				return err
+			}
+			if err := unstructured.SetNestedStringSlice(featureGate.Object, enabled, "spec", "customNoUpgrade", "enabled"); err != nil {
+				return err
+			}
+			if err := unstructured.SetNestedStringSlice(featureGate.Object, disabled, "spec", "customNoUpgrade", "disabled"); err != nil {
+				return err
+			}
+		}
+		_, err = o.dynamicClient.Resource(featureGateGVR).Update(featureGate, metav1.UpdateOptions{})
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to update featuregates/cluster: %v", err)
+	}
+
+	if o.changes() {
+		fmt.Fprintf(o.ErrOut, "WARNING: The feature set of this cluster was changed, this can not be undone and the cluster can not be upgraded anymore.\n")
+		o.recordAudit()
+	}
+	if o.techPreview {
+		o.printOut("-> Feature set TechPreviewNoUpgrade enabled ...\n")
+		return nil
+	}
+	if len(enabled) == 0 && len(disabled) == 0 {
+		o.printOut("-> No custom feature gates are set\n")
+		return nil
+	}
+
+	if !o.wait {
+		return o.printStatus(enabled, disabled)
+	}
+	o.printOut("-> Waiting for operators to pick up the feature gates ...\n")
+	err := wait.PollImmediate(5*time.Second, o.timeout, func() (bool, error) {
+		for _, config := range renderedConfigs {
+			missing, err := o.configMissingGates(config, enabled, disabled)
+			if err != nil || len(missing) > 0 {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if statusErr := o.printStatus(enabled, disabled); statusErr != nil {
+		return statusErr
+	}
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("not all operators picked up the feature gates within %s", o.timeout)
+	}
+	return err
+}
+
+// configMissingGates returns the gates missing in the rendered config map. Operators not present in the cluster are ignored.
+func (o *FeatureGateOptions) configMissingGates(config renderedConfig, enabled, disabled []string) ([]string, error) {
+	configMap, err := o.kubeClient.CoreV1().ConfigMaps(config.namespace).Get(config.name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return missingGates(configMap.Data["config.yaml"], enabled, disabled), nil
+}
+
+func (o *FeatureGateOptions) printStatus(enabled, disabled []string) error {
+	w := tabwriter.NewWriter(o.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "OPERATOR\tCONFIG\tMISSING GATES")
+	for _, config := range renderedConfigs {
+		missing, err := o.configMissingGates(config, enabled, disabled)
+		status := "<none>"
+		switch {
+		case err != nil:
+			status = err.Error()
+		case len(missing) > 0:
+			status = strings.Join(missing, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s/%s\t%s\n", config.operator, config.namespace, config.name, status)
+	}
+	return w.Flush()
+}
+
+func (o *FeatureGateOptions) recordAudit() {
+	audit.Log(o.kubeClient, o.dynamicClient, o.ErrOut, audit.Record{
+		Command: "featuregate",
+		Flags:   audit.ChangedFlags(o.flags),
+	}, nil)
+}
@@ -0,0 +1,32 @@
+package featuregate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_mergeGates(t *testing.T) {
+	enabled, disabled := mergeGates([]string{"A", "B"}, []string{"C"}, []string{"C"}, []string{"B", "D"})
+	if expected := []string{"A", "C"}; !reflect.DeepEqual(enabled, expected) {
+		t.Errorf("expected enabled %v, got %v", expected, enabled)
+	}
+	if expected := []string{"B", "D"}; !reflect.DeepEqual(disabled, expected) {
+		t.Errorf("expected disabled %v, got %v", expected, disabled)
+	}
+}
+
+func Test_missingGates(t *testing.T) {
+	config := `apiServerArguments:
+  feature-gates:
+  - A=true
+  - B=false
+  - BarFoo=true
+extendedArguments:
+  feature-gates:
+  - D=true,E=false
+`
+	missing := missingGates(config, []string{"A", "C", "Foo", "D"}, []string{"B", "E"})
+	if expected := []string{"C=true", "Foo=true"}; !reflect.DeepEqual(missing, expected) {
+		t.Errorf("expected missing %v, got %v", expected, missing)
+	}
+}
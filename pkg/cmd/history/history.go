@@ -0,0 +1,140 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/audit"
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+)
+
+// HistoryOptions provides information required to show
+// the changes made by the plugin
+type HistoryOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	args  []string
+	local bool
+
+	kubeClient kubernetes.Interface
+
+	genericclioptions.IOStreams
+}
+
+// NewHistoryOptions provides an instance of HistoryOptions with default values
+func NewHistoryOptions(streams genericclioptions.IOStreams) *HistoryOptions {
+	return &HistoryOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	operatorHistoryExample = `
+	# show all changes made to the cluster operators
+	%[1]s
+
+    # show changes made to the kube-apiserver operator from this machine
+	%[1]s kube-apiserver --local
+`
+)
+
+func NewCmdOperatorHistory(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewHistoryOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "history [clusteroperator/name|deployment/namespace/name]",
+		Short:   "Show the changes made to operators by this plugin",
+		Example: fmt.Sprintf(operatorHistoryExample, "oc operator-dev history"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.local, "local", o.local, "show the records from the local audit log instead of the cluster")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *HistoryOptions) Complete() error {
+	if o.local {
+		return nil
+	}
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.kubeClient = kubeClient
+
+	return nil
+}
+
+func (o *HistoryOptions) Run() error {
+	var (
+		records []audit.Record
+		err     error
+	)
+	if o.local {
+		records, err = audit.ListLocal()
+	} else {
+		records, err = audit.List(o.kubeClient)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read audit records: %v", err)
+	}
+
+	// override records the target ID, so "deployment/namespace/name" matches the "deployment.namespace.name" records
+	var operatorID string
+	if len(o.args) > 0 {
+		target, err := operator.ParseTarget(o.args[0])
+		if err != nil {
+			return err
+		}
+		operatorID = target.ID()
+	}
+
+	w := tabwriter.NewWriter(o.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tUSER\tCOMMAND\tOPERATOR\tOLD IMAGE\tNEW IMAGE\tFLAGS\tERROR")
+	for _, r := range records {
+		if len(operatorID) > 0 && r.Operator != operatorID {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Timestamp.Local().Format(time.RFC3339), r.User, r.Command, r.Operator,
+			valueOrNone(r.OldImage), valueOrNone(r.NewImage), valueOrNone(formatFlags(r.Flags)), valueOrNone(r.Error))
+	}
+	return w.Flush()
+}
+
+func valueOrNone(value string) string {
+	if len(value) == 0 {
+		return "<none>"
+	}
+	return value
+}
+
+func formatFlags(flags map[string]string) string {
+	var result []string
+	for name, value := range flags {
+		result = append(result, "--"+name+"="+value)
+	}
+	sort.Strings(result)
+	return strings.Join(result, " ")
+}
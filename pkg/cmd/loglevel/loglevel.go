@@ -0,0 +1,212 @@
+package loglevel
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/audit"
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+)
+
+// validLogLevels are the log levels supported by operator.openshift.io resources.
+var validLogLevels = []string{"Normal", "Debug", "Trace", "TraceAll"}
+
+// LogLevelOptions provides information required to set
+// the log level of an operator and its operand
+type LogLevelOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	flags       *pflag.FlagSet
+
+	args             []string
+	operatorLogLevel string
+	operandLogLevel  string
+
+	dynamicClient dynamic.Interface
+	kubeClient    kubernetes.Interface
+	mapper        meta.RESTMapper
+
+	genericclioptions.IOStreams
+}
+
+// NewLogLevelOptions provides an instance of LogLevelOptions with default values
+func NewLogLevelOptions(streams genericclioptions.IOStreams) *LogLevelOptions {
+	return &LogLevelOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	operatorLogLevelExample = `
+	# show the current log levels of the kube-apiserver operator and its operand
+	%[1]s kube-apiserver
+
+    # set the operator log level to Debug and operand log level to Trace.
+    # The operator.openshift.io resource (eg. kubeapiservers/cluster) is updated, no rollout of the operator is needed.
+	%[1]s kube-apiserver --operator=Debug --operand=Trace
+`
+)
+
+func NewCmdOperatorLogLevel(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewLogLevelOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "loglevel <clusteroperator/name>",
+		Short:   "Set the log level of the operator and its operand",
+		Example: fmt.Sprintf(operatorLogLevelExample, "oc operator-dev loglevel"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			o.flags = c.Flags()
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.operatorLogLevel, "operator", o.operatorLogLevel, "operator log level ("+strings.Join(validLogLevels, ", ")+")")
+	cmd.Flags().StringVar(&o.operandLogLevel, "operand", o.operandLogLevel, "operand log level ("+strings.Join(validLogLevels, ", ")+")")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// normalizeLogLevel returns the log level in the form expected by the API, or an error when the level is not valid.
+func normalizeLogLevel(level string) (string, error) {
+	for _, valid := range validLogLevels {
+		if strings.EqualFold(level, valid) {
+			return valid, nil
+		}
+	}
+	return "", fmt.Errorf("invalid log level %q, must be one of %s", level, strings.Join(validLogLevels, ", "))
+}
+
+func (o *LogLevelOptions) Validate() error {
+	if len(o.args) == 0 {
+		return fmt.Errorf("clusteroperator/name must be specified")
+	}
+	var err error
+	if len(o.operatorLogLevel) > 0 {
+		if o.operatorLogLevel, err = normalizeLogLevel(o.operatorLogLevel); err != nil {
+			return err
+		}
+	}
+	if len(o.operandLogLevel) > 0 {
+		if o.operandLogLevel, err = normalizeLogLevel(o.operandLogLevel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *LogLevelOptions) printOut(message string, objs ...interface{}) {
+	if _, err := fmt.Fprintf(o.Out, message, objs...); err != nil {
+		panic(err)
+	}
+}
+
+func (o *LogLevelOptions) Complete() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.dynamicClient = dynamicClient
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.kubeClient = kubeClient
+
+	mapper, err := o.configFlags.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+	o.mapper = mapper
+
+	return nil
+}
+
+// logLevelOrDefault returns the log level, the empty log level means Normal.
+func logLevelOrDefault(level string) string {
+	if len(level) == 0 {
+		return "Normal"
+	}
+	return level
+}
+
+func (o *LogLevelOptions) Run() error {
+	clusterOperator, err := o.dynamicClient.Resource(operator.ClusterOperatorGVR).Get(o.args[0], metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("operator %q is not valid operator: %v", o.args[0], err)
+	}
+	operatorConfig, err := operator.GetOperatorConfig(clusterOperator, o.mapper)
+	if err != nil {
+		return err
+	}
+
+	if len(o.operatorLogLevel) == 0 && len(o.operandLogLevel) == 0 {
+		obj, err := o.dynamicClient.Resource(operatorConfig.GVR).Get(operatorConfig.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		operatorLogLevel, _, _ := unstructured.NestedString(obj.Object, "spec", "operatorLogLevel")
+		operandLogLevel, _, _ := unstructured.NestedString(obj.Object, "spec", "logLevel")
+		o.printOut("-> Operator %q log level is %q, operand log level is %q\n", o.args[0], logLevelOrDefault(operatorLogLevel), logLevelOrDefault(operandLogLevel))
+		return nil
+	}
+
+	var oldOperatorLogLevel, oldOperandLogLevel string
+	if err := operatorConfig.Update(o.dynamicClient, func(obj *unstructured.Unstructured) error {
+		oldOperatorLogLevel, _, _ = unstructured.NestedString(obj.Object, "spec", "operatorLogLevel")
+		oldOperandLogLevel, _, _ = unstructured.NestedString(obj.Object, "spec", "logLevel")
+		if len(o.operatorLogLevel) > 0 {
+			if err := unstructured.SetNestedField(obj.Object, o.operatorLogLevel, "spec", "operatorLogLevel"); err != nil {
+				return err
+			}
+		}
+		if len(o.operandLogLevel) > 0 {
+			if err := unstructured.SetNestedField(obj.Object, o.operandLogLevel, "spec", "logLevel"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to update %s/%s: %v", operatorConfig.GVR.Resource, operatorConfig.Name, err)
+	}
+
+	if len(o.operatorLogLevel) > 0 {
+		o.printOut("-> Operator %q log level changed from %q to %q ...\n", o.args[0], logLevelOrDefault(oldOperatorLogLevel), o.operatorLogLevel)
+	}
+	if len(o.operandLogLevel) > 0 {
+		o.printOut("-> Operand log level changed from %q to %q ...\n", logLevelOrDefault(oldOperandLogLevel), o.operandLogLevel)
+	}
+	o.recordAudit()
+	return nil
+}
+
+func (o *LogLevelOptions) recordAudit() {
+	audit.Log(o.kubeClient, o.dynamicClient, o.ErrOut, audit.Record{
+		Command:  "loglevel",
+		Operator: o.args[0],
+		Flags:    audit.ChangedFlags(o.flags),
+	}, nil)
+}
@@ -0,0 +1,28 @@
+package loglevel
+
+import (
+	"testing"
+)
+
+func Test_normalizeLogLevel(t *testing.T) {
+	tests := map[string]string{
+		"debug":    "Debug",
+		"TRACE":    "Trace",
+		"traceall": "TraceAll",
+		"Normal":   "Normal",
+	}
+	for level, expected := range tests {
+		t.Run(level, func(t *testing.T) {
+			got, err := normalizeLogLevel(level)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != expected {
+				t.Errorf("expected %q, got %q", expected, got)
+			}
+		})
+	}
+	if _, err := normalizeLogLevel("verbose"); err == nil {
+		t.Errorf("expected error for invalid log level")
+	}
+}
@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/homedir"
+
+	opmetrics "github.com/mfojtik/operator-dev-plugin/pkg/metrics"
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+	"github.com/mfojtik/operator-dev-plugin/pkg/portforward"
+)
+
+// MetricsOptions provides information required to scrape
+// and compare the operator metrics
+type MetricsOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	args       []string
+	before     bool
+	after      bool
+	dir        string
+	deployment string
+
+	restConfig    *rest.Config
+	dynamicClient dynamic.Interface
+	kubeClient    kubernetes.Interface
+
+	genericclioptions.IOStreams
+}
+
+// NewMetricsOptions provides an instance of MetricsOptions with default values
+func NewMetricsOptions(streams genericclioptions.IOStreams) *MetricsOptions {
+	return &MetricsOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		dir:         filepath.Join(homedir.HomeDir(), ".kube", "operator-dev", "metrics"),
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	operatorMetricsExample = `
+	# show the current workqueue, client and goroutine metrics of the kube-apiserver operator
+	%[1]s kube-apiserver
+
+    # compare the operator metrics before and after overriding the operator image
+	%[1]s kube-apiserver --before
+	oc operator-dev override kube-apiserver --image=quay.io/user/cluster-kube-apiserver-operator:dev
+	%[1]s kube-apiserver --after
+`
+)
+
+func NewCmdOperatorMetrics(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewMetricsOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "metrics <clusteroperator/name>",
+		Short:   "Scrape the operator metrics and compare them before and after a change",
+		Example: fmt.Sprintf(operatorMetricsExample, "oc operator-dev metrics"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.before, "before", o.before, "save the metrics snapshot taken before a change")
+	cmd.Flags().BoolVar(&o.after, "after", o.after, "save the metrics snapshot taken after a change and compare it with the snapshot taken before")
+	cmd.Flags().StringVar(&o.dir, "dir", o.dir, "directory to store the metrics snapshots in")
+	cmd.Flags().StringVar(&o.deployment, "deployment", o.deployment, "custom deployment name")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *MetricsOptions) Validate() error {
+	if len(o.args) == 0 {
+		return fmt.Errorf("clusteroperator/name must be specified")
+	}
+	if o.before && o.after {
+		return fmt.Errorf("only one of --before or --after can be specified")
+	}
+	return nil
+}
+
+func (o *MetricsOptions) printOut(message string, objs ...interface{}) {
+	if _, err := fmt.Fprintf(o.Out, message, objs...); err != nil {
+		panic(err)
+	}
+}
+
+func (o *MetricsOptions) Complete() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	o.restConfig = restConfig
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.dynamicClient = dynamicClient
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.kubeClient = kubeClient
+
+	return nil
+}
+
+func (o *MetricsOptions) snapshotPath(name string) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%s-%s.prom", o.args[0], name))
+}
+
+func (o *MetricsOptions) Run() error {
+	if _, err := o.dynamicClient.Resource(operator.ClusterOperatorGVR).Get(o.args[0], metav1.GetOptions{}); err != nil {
+		return fmt.Errorf("operator %q is not valid operator: %v", o.args[0], err)
+	}
+
+	// read the before snapshot first, so we do not scrape for nothing
+	var beforeSamples []opmetrics.Sample
+	if o.after {
+		data, err := ioutil.ReadFile(o.snapshotPath("before"))
+		if err != nil {
+			return fmt.Errorf("unable to read metrics snapshot (run with --before first): %v", err)
+		}
+		if beforeSamples, err = opmetrics.Parse(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("unable to parse %s: %v", o.snapshotPath("before"), err)
+		}
+	}
+
+	data, err := o.scrape()
+	if err != nil {
+		return err
+	}
+	samples, err := opmetrics.Parse(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("unable to parse operator metrics: %v", err)
+	}
+
+	switch {
+	case o.before:
+		return o.save("before", data)
+	case o.after:
+		if err := o.save("after", data); err != nil {
+			return err
+		}
+		return o.printChanges(opmetrics.Compare(opmetrics.Summarize(beforeSamples), opmetrics.Summarize(samples)))
+	default:
+		return o.printChanges(opmetrics.Compare(nil, opmetrics.Summarize(samples)))
+	}
+}
+
+func (o *MetricsOptions) scrape() ([]byte, error) {
+	deployment, err := operator.ResolveDeployment(o.kubeClient, o.args[0], o.deployment)
+	if err != nil {
+		return nil, err
+	}
+	token, err := portforward.BearerToken(o.restConfig)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := portforward.FindOperatorEndpoint(o.kubeClient, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	localPort, err := portforward.Forward(o.restConfig, o.kubeClient, endpoint, stopCh)
+	if err != nil {
+		return nil, err
+	}
+	o.printOut("-> Scraping metrics from pod %s/%s (port %d) ...\n", endpoint.Namespace, endpoint.Pod, endpoint.Port)
+	data, err := portforward.Get(endpoint.Scheme, localPort, "/metrics", token, time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("unable to scrape operator metrics: %v", err)
+	}
+	return data, nil
+}
+
+func (o *MetricsOptions) save(name string, data []byte) error {
+	if err := os.MkdirAll(o.dir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(o.snapshotPath(name), data, 0644); err != nil {
+		return err
+	}
+	o.printOut("-> Metrics snapshot saved to %s\n", o.snapshotPath(name))
+	return nil
+}
+
+// formatChange returns the relative change of the series value.
+func formatChange(change opmetrics.Change) string {
+	switch {
+	case !change.HasBefore || !change.HasAfter:
+		return "-"
+	case change.Before == change.After:
+		return "0%"
+	case change.Before == 0:
+		return "n/a"
+	default:
+		return fmt.Sprintf("%+.1f%%", (change.After-change.Before)/change.Before*100)
+	}
+}
+
+func formatValue(value float64, ok bool) string {
+	if !ok {
+		return "<none>"
+	}
+	return strconv.FormatFloat(value, 'g', 6, 64)
+}
+
+func (o *MetricsOptions) printChanges(changes []opmetrics.Change) error {
+	w := tabwriter.NewWriter(o.Out, 0, 4, 2, ' ', 0)
+	if o.after {
+		fmt.Fprintln(w, "SERIES\tBEFORE\tAFTER\tCHANGE")
+	} else {
+		fmt.Fprintln(w, "SERIES\tVALUE")
+	}
+	for _, change := range changes {
+		if o.after {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", change.Key, formatValue(change.Before, change.HasBefore), formatValue(change.After, change.HasAfter), formatChange(change))
+		} else {
+			fmt.Fprintf(w, "%s\t%s\n", change.Key, formatValue(change.After, change.HasAfter))
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,442 @@
+package olminstall
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/audit"
+	"github.com/mfojtik/operator-dev-plugin/pkg/identity"
+	"github.com/mfojtik/operator-dev-plugin/pkg/olm"
+)
+
+// OLMInstallOptions provides information required to install
+// or uninstall an operator bundle with OLM
+type OLMInstallOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	flags       *pflag.FlagSet
+
+	args          []string
+	uninstall     bool
+	bundle        string
+	index         string
+	channel       string
+	opmImage      string
+	allNamespaces bool
+	timeout       time.Duration
+
+	namespace string
+	user      string
+
+	dynamicClient dynamic.Interface
+	kubeClient    kubernetes.Interface
+
+	genericclioptions.IOStreams
+}
+
+// NewOLMInstallOptions provides an instance of OLMInstallOptions with default values
+func NewOLMInstallOptions(streams genericclioptions.IOStreams) *OLMInstallOptions {
+	return &OLMInstallOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		opmImage:    olm.DefaultRegistryImage,
+		timeout:     10 * time.Minute,
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	olmInstallExample = `
+	# install the local-storage-operator package from a bundle image into the openshift-local-storage namespace
+	%[1]s local-storage-operator --bundle=quay.io/me/local-storage-operator-bundle:debug -n openshift-local-storage
+
+    # install the cluster-logging package from the stable channel of an index image
+	%[1]s cluster-logging --index=quay.io/me/logging-index:debug --channel=stable -n openshift-logging
+`
+
+	olmUninstallExample = `
+	# remove the local-storage-operator package and everything olm-install created for it
+	%[1]s local-storage-operator
+`
+)
+
+func NewCmdOperatorOLMInstall(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewOLMInstallOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "olm-install <package> --bundle=IMAGE|--index=IMAGE",
+		Short:   "Install an operator package from a bundle or index image with OLM",
+		Example: fmt.Sprintf(olmInstallExample, "oc operator-dev olm-install"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			o.flags = c.Flags()
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.bundle, "bundle", o.bundle, "operator bundle image to install")
+	cmd.Flags().StringVar(&o.index, "index", o.index, "index image with the operator package to install")
+	cmd.Flags().StringVar(&o.channel, "channel", o.channel, "package channel to subscribe to (default channel of the package when empty)")
+	cmd.Flags().StringVar(&o.opmImage, "opm-image", o.opmImage, "opm image serving the --bundle image")
+	cmd.Flags().BoolVar(&o.allNamespaces, "all-namespaces", o.allNamespaces, "create an operator group for all namespaces instead of the install namespace only")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", o.timeout, "how long to wait for the clusterserviceversion to succeed")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func NewCmdOperatorOLMUninstall(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewOLMInstallOptions(streams)
+	o.uninstall = true
+
+	cmd := &cobra.Command{
+		Use:     "olm-uninstall <package>",
+		Short:   "Remove an operator package installed with olm-install",
+		Example: fmt.Sprintf(olmUninstallExample, "oc operator-dev olm-uninstall"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			o.flags = c.Flags()
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *OLMInstallOptions) Validate() error {
+	if len(o.args) == 0 {
+		return fmt.Errorf("package name must be specified")
+	}
+	if o.uninstall {
+		return nil
+	}
+	if len(o.bundle) == 0 && len(o.index) == 0 {
+		return fmt.Errorf("one of --bundle or --index must be specified")
+	}
+	if len(o.bundle) > 0 && len(o.index) > 0 {
+		return fmt.Errorf("only one of --bundle or --index can be specified")
+	}
+	return nil
+}
+
+func (o *OLMInstallOptions) printOut(message string, objs ...interface{}) {
+	if _, err := fmt.Fprintf(o.Out, message, objs...); err != nil {
+		panic(err)
+	}
+}
+
+func (o *OLMInstallOptions) Complete() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.dynamicClient = dynamicClient
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.kubeClient = kubeClient
+
+	// the package is never installed into the namespace of the current context by accident, olm-uninstall uses the
+	// namespace the package was installed into
+	namespace, explicit, err := o.configFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return err
+	}
+	if !o.uninstall && !explicit {
+		return fmt.Errorf("namespace to install the package into must be specified with -n")
+	}
+	o.namespace = namespace
+
+	return nil
+}
+
+func (o *OLMInstallOptions) Run() (err error) {
+	user, err := identity.CurrentUser(o.dynamicClient)
+	if err != nil {
+		return err
+	}
+	o.user = user
+
+	// every change made to the cluster is recorded, including the failed ones
+	defer func() {
+		o.recordAudit(err)
+	}()
+
+	if o.uninstall {
+		return o.uninstallPackage()
+	}
+	return o.installPackage()
+}
+
+func (o *OLMInstallOptions) installPackage() error {
+	packageName := o.args[0]
+	existing, err := olm.LoadInstallState(o.kubeClient, packageName)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("package %q is already installed in namespace %q, run 'oc operator-dev olm-uninstall %s' first", packageName, existing.Namespace, packageName)
+	}
+
+	// the state is saved after every created object, so olm-uninstall can clean up after a failed install too
+	state := &olm.InstallState{Package: packageName, Namespace: o.namespace}
+	created := func(object olm.Object) error {
+		state.Created = append(state.Created, object)
+		o.printOut("-> Created %s\n", object)
+		return state.Save(o.kubeClient)
+	}
+
+	if _, err := o.kubeClient.CoreV1().Namespaces().Get(o.namespace, metav1.GetOptions{}); errors.IsNotFound(err) {
+		if _, err := o.kubeClient.CoreV1().Namespaces().Create(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: o.namespace}}); err != nil {
+			return fmt.Errorf("unable to create namespace %q: %v", o.namespace, err)
+		}
+		if err := created(olm.Object{GVR: olm.NamespaceGVR, Name: o.namespace}); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return fmt.Errorf("unable to get namespace %q: %v", o.namespace, err)
+	}
+
+	// OLM can not install a bundle image directly, it is added into a new index served by a registry pod
+	if len(o.bundle) > 0 {
+		pod, service, err := olm.BundleRegistry(o.namespace, packageName, o.bundle, o.opmImage)
+		if err != nil {
+			return err
+		}
+		if _, err := o.dynamicClient.Resource(olm.PodGVR).Namespace(o.namespace).Create(pod, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("unable to create bundle registry pod: %v", err)
+		}
+		if err := created(olm.Object{GVR: olm.PodGVR, Namespace: o.namespace, Name: pod.GetName()}); err != nil {
+			return err
+		}
+		if _, err := o.kubeClient.CoreV1().Services(o.namespace).Create(service); err != nil {
+			return fmt.Errorf("unable to create bundle registry service: %v", err)
+		}
+		if err := created(olm.Object{GVR: olm.ServiceGVR, Namespace: o.namespace, Name: service.Name}); err != nil {
+			return err
+		}
+	}
+
+	objects := []*unstructured.Unstructured{olm.CatalogSource(o.namespace, packageName, o.index)}
+	operatorGroups, err := o.dynamicClient.Resource(olm.OperatorGroupGVR).Namespace(o.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to list operator groups in namespace %q: %v", o.namespace, err)
+	}
+	if len(operatorGroups.Items) == 0 {
+		objects = append(objects, olm.OperatorGroup(o.namespace, packageName, o.allNamespaces))
+	} else {
+		o.printOut("-> Using existing operatorgroup %s\n", operatorGroups.Items[0].GetName())
+	}
+	objects = append(objects, olm.Subscription(o.namespace, packageName, o.channel))
+
+	for _, obj := range objects {
+		gvr := olm.CatalogSourceGVR
+		switch obj.GetKind() {
+		case "OperatorGroup":
+			gvr = olm.OperatorGroupGVR
+		case "Subscription":
+			gvr = olm.SubscriptionGVR
+		}
+		if _, err := o.dynamicClient.Resource(gvr).Namespace(o.namespace).Create(obj, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("unable to create %s %q: %v", obj.GetKind(), obj.GetName(), err)
+		}
+		if err := created(olm.Object{GVR: gvr, Namespace: o.namespace, Name: obj.GetName()}); err != nil {
+			return err
+		}
+	}
+
+	return o.waitForInstall(packageName)
+}
+
+// waitForInstall follows the catalog source, install plan and clusterserviceversion and prints every phase they go through.
+// The install plan of the subscription is approved, the install is done when the clusterserviceversion succeeds.
+func (o *OLMInstallOptions) waitForInstall(packageName string) error {
+	phases := map[string]string{}
+	report := func(object, phase string) {
+		if len(phase) > 0 && phases[object] != phase {
+			phases[object] = phase
+			o.printOut("-> %s: %s\n", object, phase)
+		}
+	}
+
+	var csvName string
+	err := wait.PollImmediate(2*time.Second, o.timeout, func() (bool, error) {
+		catalog, err := o.dynamicClient.Resource(olm.CatalogSourceGVR).Namespace(o.namespace).Get(olm.CatalogName(packageName), metav1.GetOptions{})
+		if err == nil {
+			state, _, _ := unstructured.NestedString(catalog.Object, "status", "connectionState", "lastObservedState")
+			report("catalogsource/"+catalog.GetName(), state)
+		}
+
+		subscription, err := o.dynamicClient.Resource(olm.SubscriptionGVR).Namespace(o.namespace).Get(packageName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		state, _, _ := unstructured.NestedString(subscription.Object, "status", "state")
+		report("subscription/"+packageName, state)
+
+		installPlanName, _, _ := unstructured.NestedString(subscription.Object, "status", "installPlanRef", "name")
+		if len(installPlanName) > 0 {
+			if err := o.approveInstallPlan(installPlanName, report); err != nil {
+				return false, err
+			}
+		}
+
+		csvName = installedCSV(subscription)
+		if len(csvName) == 0 {
+			return false, nil
+		}
+		csv, err := o.dynamicClient.Resource(olm.ClusterServiceVersionGVR).Namespace(o.namespace).Get(csvName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		phase, _, _ := unstructured.NestedString(csv.Object, "status", "phase")
+		report("clusterserviceversion/"+csvName, phase)
+		switch phase {
+		case "Succeeded":
+			return true, nil
+		case "Failed":
+			message, _, _ := unstructured.NestedString(csv.Object, "status", "message")
+			return false, fmt.Errorf("clusterserviceversion %s failed: %s", csvName, message)
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("package %q was not installed within %s, run 'oc operator-dev olm-uninstall %s' to clean up", packageName, o.timeout, packageName)
+	}
+	if err != nil {
+		return err
+	}
+	o.printOut("-> Package %q installed as clusterserviceversion %s in namespace %q\n", packageName, csvName, o.namespace)
+	return nil
+}
+
+// approveInstallPlan approves the install plan created for the subscription and reports its phase.
+func (o *OLMInstallOptions) approveInstallPlan(name string, report func(object, phase string)) error {
+	installPlans := o.dynamicClient.Resource(olm.InstallPlanGVR).Namespace(o.namespace)
+	installPlan, err := installPlans.Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+	phase, _, _ := unstructured.NestedString(installPlan.Object, "status", "phase")
+	report("installplan/"+name, phase)
+	if phase == "Failed" {
+		return fmt.Errorf("installplan %s failed", name)
+	}
+	if approved, _, _ := unstructured.NestedBool(installPlan.Object, "spec", "approved"); approved {
+		return nil
+	}
+	if _, err := installPlans.Patch(name, types.MergePatchType, []byte(`{"spec":{"approved":true}}`), metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("unable to approve installplan %s: %v", name, err)
+	}
+	o.printOut("-> Approved installplan %s\n", name)
+	return nil
+}
+
+// installedCSV returns the clusterserviceversion installed by the subscription, or the one being installed.
+func installedCSV(subscription *unstructured.Unstructured) string {
+	if name, _, _ := unstructured.NestedString(subscription.Object, "status", "installedCSV"); len(name) > 0 {
+		return name
+	}
+	name, _, _ := unstructured.NestedString(subscription.Object, "status", "currentCSV")
+	return name
+}
+
+func (o *OLMInstallOptions) uninstallPackage() error {
+	packageName := o.args[0]
+	state, err := olm.LoadInstallState(o.kubeClient, packageName)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return fmt.Errorf("package %q was not installed with 'oc operator-dev olm-install'", packageName)
+	}
+	o.namespace = state.Namespace
+
+	// the clusterserviceversion is created by OLM, it is deleted right after the subscription, so the operator is removed
+	// and OLM does not install it again
+	csvName := ""
+	subscription, err := o.dynamicClient.Resource(olm.SubscriptionGVR).Namespace(state.Namespace).Get(packageName, metav1.GetOptions{})
+	if err == nil {
+		csvName = installedCSV(subscription)
+	} else if !errors.IsNotFound(err) {
+		return fmt.Errorf("unable to get subscription %q: %v", packageName, err)
+	}
+	var objects []olm.Object
+	for i := len(state.Created) - 1; i >= 0; i-- {
+		objects = append(objects, state.Created[i])
+		if state.Created[i].GVR == olm.SubscriptionGVR && len(csvName) > 0 {
+			objects = append(objects, olm.Object{GVR: olm.ClusterServiceVersionGVR, Namespace: state.Namespace, Name: csvName})
+		}
+	}
+
+	for _, object := range objects {
+		var resource dynamic.ResourceInterface = o.dynamicClient.Resource(object.GVR)
+		if len(object.Namespace) > 0 {
+			resource = o.dynamicClient.Resource(object.GVR).Namespace(object.Namespace)
+		}
+		err := resource.Delete(object.Name, &metav1.DeleteOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to delete %s: %v", object, err)
+		}
+		o.printOut("-> Deleted %s\n", object)
+	}
+	if err := state.Remove(o.kubeClient); err != nil {
+		return err
+	}
+	o.printOut("-> Package %q uninstalled\n", packageName)
+	return nil
+}
+
+func (o *OLMInstallOptions) recordAudit(runErr error) {
+	command := "olm-install"
+	if o.uninstall {
+		command = "olm-uninstall"
+	}
+	record := audit.Record{
+		User:      o.user,
+		Command:   command,
+		Operator:  o.args[0],
+		Namespace: o.namespace,
+		NewImage:  o.bundle,
+		Flags:     audit.ChangedFlags(o.flags),
+	}
+	if len(o.index) > 0 {
+		record.NewImage = o.index
+	}
+	audit.Log(o.kubeClient, o.dynamicClient, o.ErrOut, record, runErr)
+}
@@ -0,0 +1,420 @@
+package operand
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/audit"
+	"github.com/mfojtik/operator-dev-plugin/pkg/identity"
+	"github.com/mfojtik/operator-dev-plugin/pkg/lock"
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+	"github.com/mfojtik/operator-dev-plugin/pkg/transaction"
+)
+
+// OperandOptions provides information required to override
+// the image of the workloads managed by an operator
+type OperandOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	flags       *pflag.FlagSet
+
+	args      []string
+	image     string
+	workload  string
+	container string
+	managed   bool
+	force     bool
+	timeout   time.Duration
+
+	dynamicClient dynamic.Interface
+	kubeClient    kubernetes.Interface
+	mapper        meta.RESTMapper
+
+	genericclioptions.IOStreams
+}
+
+// NewOperandOptions provides an instance of OperandOptions with default values
+func NewOperandOptions(streams genericclioptions.IOStreams) *OperandOptions {
+	return &OperandOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		timeout:     10 * time.Minute,
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	operandOverrideExample = `
+	# operand will stop both the cluster version operator and the operator from managing the operand and replace the operand image.
+    # The 'openshift-apiserver' must be valid cluster operator name (oc get clusteroperators).
+	%[1]s openshift-apiserver --image=docker.io/foo/openshift-apiserver:debug
+
+    # the workload must be specified when the operator manages more than one
+	%[1]s dns --workload=daemonset/dns-default --container=dns --image=docker.io/foo/coredns:debug
+
+    # will make the openshift apiserver operator manage its operand again
+	%[1]s openshift-apiserver --managed
+`
+)
+
+func NewCmdOperandOverride(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewOperandOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "operand <clusteroperator/name>",
+		Short:   "Override the image of the operand managed by the target operator",
+		Example: fmt.Sprintf(operandOverrideExample, "oc operator-dev operand"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			o.flags = c.Flags()
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.image, "image", o.image, "image to use for the operand")
+	cmd.Flags().StringVar(&o.workload, "workload", o.workload, "operand deployment, daemonset or statefulset to override in kind/name format (eg. daemonset/dns-default)")
+	cmd.Flags().StringVar(&o.container, "container", o.container, "operand container to override (defaults to the first container)")
+	cmd.Flags().BoolVar(&o.managed, "managed", false, "set to true if you want the operator to manage its operand again")
+	cmd.Flags().BoolVar(&o.force, "force", o.force, "take over the operator lock even when it is held by another user")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", o.timeout, "how long to wait for the operand to roll out")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *OperandOptions) Validate() error {
+	if len(o.args) == 0 {
+		return fmt.Errorf("clusteroperator/name must be specified")
+	}
+	if len(o.image) != 0 && o.managed {
+		return fmt.Errorf("image must be empty when operand is managed")
+	}
+	if len(o.image) == 0 && !o.managed {
+		return fmt.Errorf("image must be specified")
+	}
+	if len(o.workload) > 0 {
+		if _, _, err := parseWorkload(o.workload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *OperandOptions) printOut(message string, objs ...interface{}) {
+	if _, err := fmt.Fprintf(o.Out, message, objs...); err != nil {
+		panic(err)
+	}
+}
+
+func (o *OperandOptions) Complete() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.dynamicClient = dynamicClient
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.kubeClient = kubeClient
+
+	mapper, err := o.configFlags.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+	o.mapper = mapper
+
+	return nil
+}
+
+// workload is a deployment, daemon set or stateful set running the operand.
+type workload struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+func (w workload) String() string {
+	return operator.WorkloadString(w.kind, w.namespace, w.name)
+}
+
+// parseWorkload parses the workload in kind/name format.
+func parseWorkload(value string) (string, string, error) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return "", "", fmt.Errorf("invalid workload %q, must be in kind/name format", value)
+	}
+	kind, err := operator.ParseWorkloadKind(parts[0])
+	if err != nil {
+		return "", "", fmt.Errorf("invalid workload %q: %v", value, err)
+	}
+	return kind, parts[1], nil
+}
+
+// lockName is the operator lock taken by operand. It differs from the lock taken by override, pause and bisect, as the
+// operand can be overridden while somebody else overrides the operator.
+func lockName(operatorName string) string {
+	return "operand." + operatorName
+}
+
+func (o *OperandOptions) Run() (err error) {
+	clusterOperator, err := o.dynamicClient.Resource(operator.ClusterOperatorGVR).Get(o.args[0], metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("operator %q is not valid operator: %v", o.args[0], err)
+	}
+	operatorConfig, err := operator.GetOperatorConfig(clusterOperator, o.mapper)
+	if err != nil {
+		return err
+	}
+
+	user, err := identity.CurrentUser(o.dynamicClient)
+	if err != nil {
+		return err
+	}
+
+	// clusters without the cluster version operator (eg. kind) do not need the clusterversion override
+	clusterVersion, err := operator.HasClusterVersion(o.dynamicClient)
+	if err != nil {
+		return err
+	}
+	component := operatorConfig.ComponentOverride()
+
+	if o.managed {
+		if err := lock.Acquire(o.kubeClient, lockName(o.args[0]), user, lock.DefaultDuration, o.force); err != nil {
+			return err
+		}
+		if _, err := operatorConfig.SetManagementState(o.dynamicClient, "Managed"); err != nil {
+			return fmt.Errorf("failed to set %s/%s managed: %v", operatorConfig.GVR.Resource, operatorConfig.Name, err)
+		}
+		if clusterVersion {
+			unmanaged, err := operator.IsUnmanaged(o.dynamicClient, component)
+			if err != nil {
+				return err
+			}
+			if unmanaged {
+				if err := operator.SetUnmanaged(o.dynamicClient, component, false); err != nil {
+					return err
+				}
+			}
+		}
+		if err := lock.Release(o.kubeClient, lockName(o.args[0]), user, o.force); err != nil {
+			return fmt.Errorf("failed to release operator lock: %v", err)
+		}
+		o.recordAudit(user, "", "")
+		o.printOut("-> Operator %q now manages its operand ...\n", o.args[0])
+		return nil
+	}
+
+	target, err := o.findWorkload(clusterOperator)
+	if err != nil {
+		return err
+	}
+
+	// the changes are made as a transaction, so a failure or interrupt does not leave the operator unmanaged
+	ctx, cancel := transaction.WithSignals(context.Background())
+	defer cancel()
+	tx := transaction.New(o.Out)
+	defer func() {
+		if err == nil || tx.Empty() {
+			return
+		}
+		o.printOut("-> Override of operand %s failed (%v), rolling back ...\n", target, err)
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			err = fmt.Errorf("%v (%v)", err, rollbackErr)
+			return
+		}
+		err = fmt.Errorf("%v (changes were rolled back)", err)
+	}()
+
+	if err := tx.Run(ctx, lock.Step(o.kubeClient, lockName(o.args[0]), lock.Options{
+		Holder:    user,
+		Duration:  lock.DefaultDuration,
+		Force:     o.force,
+		Kind:      target.kind,
+		Namespace: target.namespace,
+		Name:      target.name,
+	})); err != nil {
+		return err
+	}
+
+	// the cluster version operator must not revert the management state and the operator must not revert the operand
+	if clusterVersion {
+		if err := tx.Run(ctx, operator.UnmanagedStep(o.dynamicClient, component)); err != nil {
+			return err
+		}
+	}
+
+	var previousState string
+	if err := tx.Run(ctx, transaction.Step{
+		Name: "operator management state",
+		Do: func(ctx context.Context) error {
+			previous, err := operatorConfig.SetManagementState(o.dynamicClient, "Unmanaged")
+			if err != nil {
+				return fmt.Errorf("failed to set %s/%s unmanaged: %v", operatorConfig.GVR.Resource, operatorConfig.Name, err)
+			}
+			previousState = previous
+			return nil
+		},
+		Undo: func() error {
+			// the management state defaults to Managed when it is not set
+			if len(previousState) == 0 {
+				previousState = "Managed"
+			}
+			_, err := operatorConfig.SetManagementState(o.dynamicClient, previousState)
+			return err
+		},
+	}); err != nil {
+		return err
+	}
+	o.printOut("-> Operator %q does not manage its operand ...\n", o.args[0])
+
+	var oldImage string
+	var previousTemplate *corev1.PodTemplateSpec
+	if err := tx.Run(ctx, transaction.Step{
+		Name: "operand " + strings.ToLower(target.kind),
+		Do: func(ctx context.Context) error {
+			var err error
+			oldImage, previousTemplate, err = o.updateImage(target)
+			return err
+		},
+		Undo: func() error {
+			_, err := operator.UpdatePodTemplate(o.kubeClient, target.kind, target.namespace, target.name, func(template *corev1.PodTemplateSpec) error {
+				*template = *previousTemplate
+				return nil
+			})
+			return err
+		},
+	}); err != nil {
+		return err
+	}
+
+	// the operand is overridden, a failed rollout below is reported but not rolled back
+	tx.Commit()
+	cancel()
+
+	o.recordAudit(user, oldImage, o.image)
+	o.printOut("-> Operand %s image is now %q, waiting for rollout ...\n", target, o.image)
+
+	if err := operator.WaitForRollout(o.kubeClient, target.kind, target.namespace, target.name, o.timeout); err != nil {
+		return err
+	}
+	o.printOut("-> Operand %s rolled out\n", target)
+	return nil
+}
+
+// findWorkload finds the operand workload in the namespaces listed in the clusteroperator related objects.
+func (o *OperandOptions) findWorkload(clusterOperator *unstructured.Unstructured) (*workload, error) {
+	operatorNamespace := operator.GetOperatorNamespace(o.args[0])
+
+	var candidates []workload
+	for _, related := range operator.GetRelatedObjects(clusterOperator) {
+		if related.Group != "" || related.Resource != "namespaces" || related.Name == operatorNamespace {
+			continue
+		}
+		deployments, err := o.kubeClient.AppsV1().Deployments(related.Name).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get deployments in namespace %s: %v", related.Name, err)
+		}
+		for _, d := range deployments.Items {
+			candidates = append(candidates, workload{kind: operator.DeploymentKind, namespace: d.Namespace, name: d.Name})
+		}
+		daemonSets, err := o.kubeClient.AppsV1().DaemonSets(related.Name).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get daemonsets in namespace %s: %v", related.Name, err)
+		}
+		for _, ds := range daemonSets.Items {
+			candidates = append(candidates, workload{kind: operator.DaemonSetKind, namespace: ds.Namespace, name: ds.Name})
+		}
+		statefulSets, err := o.kubeClient.AppsV1().StatefulSets(related.Name).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get statefulsets in namespace %s: %v", related.Name, err)
+		}
+		for _, sts := range statefulSets.Items {
+			candidates = append(candidates, workload{kind: operator.StatefulSetKind, namespace: sts.Namespace, name: sts.Name})
+		}
+	}
+
+	if len(o.workload) > 0 {
+		kind, name, _ := parseWorkload(o.workload)
+		for i := range candidates {
+			if candidates[i].kind == kind && candidates[i].name == name {
+				return &candidates[i], nil
+			}
+		}
+		return nil, fmt.Errorf("operand %s not found in operator %q namespaces", o.workload, o.args[0])
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("no operand deployments, daemonsets or statefulsets found for operator %q", o.args[0])
+	case 1:
+		return &candidates[0], nil
+	default:
+		var names []string
+		for _, c := range candidates {
+			names = append(names, strings.ToLower(c.kind)+"/"+c.name)
+		}
+		return nil, fmt.Errorf("operator %q has multiple operands (%s), use --workload to select one", o.args[0], strings.Join(names, ", "))
+	}
+}
+
+// updateImage sets the operand container image and returns the previous image and pod template.
+func (o *OperandOptions) updateImage(target *workload) (string, *corev1.PodTemplateSpec, error) {
+	oldImage := ""
+	previous, err := operator.UpdatePodTemplate(o.kubeClient, target.kind, target.namespace, target.name, func(template *corev1.PodTemplateSpec) error {
+		var err error
+		oldImage, err = setContainerImage(&template.Spec, o.container, o.image)
+		return err
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to update operand %s: %v", target, err)
+	}
+	return oldImage, previous, nil
+}
+
+// setContainerImage sets the image of the named container (or the first container) and returns the previous image.
+func setContainerImage(spec *corev1.PodSpec, container, image string) (string, error) {
+	for i := range spec.Containers {
+		if len(container) > 0 && spec.Containers[i].Name != container {
+			continue
+		}
+		oldImage := spec.Containers[i].Image
+		spec.Containers[i].Image = image
+		return oldImage, nil
+	}
+	return "", fmt.Errorf("container %q not found", container)
+}
+
+func (o *OperandOptions) recordAudit(user, oldImage, newImage string) {
+	audit.Log(o.kubeClient, o.dynamicClient, o.ErrOut, audit.Record{
+		User:     user,
+		Command:  "operand",
+		Operator: o.args[0],
+		OldImage: oldImage,
+		NewImage: newImage,
+		Flags:    audit.ChangedFlags(o.flags),
+	}, nil)
+}
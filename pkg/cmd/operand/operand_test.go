@@ -0,0 +1,37 @@
+package operand
+
+import (
+	"testing"
+)
+
+func Test_parseWorkload(t *testing.T) {
+	tests := map[string]struct {
+		kind        string
+		name        string
+		expectError bool
+	}{
+		"deployment/apiserver": {kind: "Deployment", name: "apiserver"},
+		"ds/dns-default":       {kind: "DaemonSet", name: "dns-default"},
+		"statefulset/foo":      {kind: "StatefulSet", name: "foo"},
+		"pod/foo":              {expectError: true},
+		"apiserver":            {expectError: true},
+	}
+
+	for value, test := range tests {
+		t.Run(value, func(t *testing.T) {
+			kind, name, err := parseWorkload(value)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if kind != test.kind || name != test.name {
+				t.Errorf("expected %s/%s, got %s/%s", test.kind, test.name, kind, name)
+			}
+		})
+	}
+}
@@ -21,7 +21,21 @@ import (
 
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/assert"
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/bisect"
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/config"
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/featuregate"
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/history"
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/loglevel"
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/metrics"
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/olminstall"
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/operand"
 	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/override"
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/overrideconfig"
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/pause"
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/pprof"
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/reap"
+	"github.com/mfojtik/operator-dev-plugin/pkg/cmd/restart"
 )
 
 func NewCmdOperatorDev(streams genericclioptions.IOStreams) *cobra.Command {
@@ -35,6 +49,22 @@ func NewCmdOperatorDev(streams genericclioptions.IOStreams) *cobra.Command {
 	}
 
 	cmd.AddCommand(override.NewCmdOperatorReplace(streams))
+	cmd.AddCommand(operand.NewCmdOperandOverride(streams))
+	cmd.AddCommand(overrideconfig.NewCmdOperatorOverrideConfig(streams))
+	cmd.AddCommand(featuregate.NewCmdOperatorFeatureGate(streams))
+	cmd.AddCommand(restart.NewCmdOperatorRestart(streams))
+	cmd.AddCommand(pause.NewCmdOperatorPause(streams))
+	cmd.AddCommand(pause.NewCmdOperatorResume(streams))
+	cmd.AddCommand(pprof.NewCmdOperatorPprof(streams))
+	cmd.AddCommand(metrics.NewCmdOperatorMetrics(streams))
+	cmd.AddCommand(bisect.NewCmdOperatorBisect(streams))
+	cmd.AddCommand(olminstall.NewCmdOperatorOLMInstall(streams))
+	cmd.AddCommand(olminstall.NewCmdOperatorOLMUninstall(streams))
+	cmd.AddCommand(loglevel.NewCmdOperatorLogLevel(streams))
+	cmd.AddCommand(config.NewCmdOperatorConfig(streams))
+	cmd.AddCommand(assert.NewCmdOperatorAssert(streams))
+	cmd.AddCommand(history.NewCmdOperatorHistory(streams))
+	cmd.AddCommand(reap.NewCmdOperatorReap(streams))
 
 	return cmd
 }
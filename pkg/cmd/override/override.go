@@ -1,35 +1,90 @@
 package override
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/util/homedir"
 	"k8s.io/client-go/util/retry"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/audit"
+	"github.com/mfojtik/operator-dev-plugin/pkg/identity"
+	"github.com/mfojtik/operator-dev-plugin/pkg/lock"
+	"github.com/mfojtik/operator-dev-plugin/pkg/manifests"
+	"github.com/mfojtik/operator-dev-plugin/pkg/olm"
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+	"github.com/mfojtik/operator-dev-plugin/pkg/registry"
+	"github.com/mfojtik/operator-dev-plugin/pkg/revert"
+	"github.com/mfojtik/operator-dev-plugin/pkg/snapshot"
+	"github.com/mfojtik/operator-dev-plugin/pkg/transaction"
 )
 
 // OverrideOptions provides information required to update
 // the current context on a user's KUBECONFIG
 type OverrideOptions struct {
 	configFlags *genericclioptions.ConfigFlags
+	flags       *pflag.FlagSet
 
 	args       []string
+	target     *operator.Target
 	image      string
 	operand    string
 	deployment string
 	verbosity  string
 	managed    bool
+	snapshot   string
+
+	manifests       string
+	imageReferences map[string]string
+
+	skipPreflight    bool
+	insecureRegistry bool
+
+	pullSecretFile string
+	registryAuth   bool
+	pullSecret     *registry.DockerConfig
+
+	force        bool
+	lockDuration time.Duration
+	ttl          time.Duration
+	user         string
+
+	rolloutTimeout time.Duration
+
+	waitRevision    bool
+	revisionTimeout time.Duration
+
+	rollbackOnFailure bool
+	healthTimeout     time.Duration
+	crashLogs         string
+
+	expect        string
+	expectStable  time.Duration
+	expectTimeout time.Duration
+	expectations  []operator.ConditionExpectation
 
-	dynamicClient dynamic.Interface
-	kubeClient    kubernetes.Interface
+	dynamicClient  dynamic.Interface
+	kubeClient     kubernetes.Interface
+	mapper         meta.RESTMapper
+	clusterVersion bool
 
 	genericclioptions.IOStreams
 }
@@ -37,7 +92,13 @@ type OverrideOptions struct {
 // NewOverrideOptions provides an instance of OverrideOptions with default values
 func NewOverrideOptions(streams genericclioptions.IOStreams) *OverrideOptions {
 	return &OverrideOptions{
-		configFlags: genericclioptions.NewConfigFlags(true),
+		configFlags:     genericclioptions.NewConfigFlags(true),
+		lockDuration:    lock.DefaultDuration,
+		rolloutTimeout:  10 * time.Minute,
+		revisionTimeout: 30 * time.Minute,
+		expectStable:    30 * time.Second,
+		expectTimeout:   10 * time.Minute,
+		healthTimeout:   5 * time.Minute,
 
 		IOStreams: streams,
 	}
@@ -51,6 +112,36 @@ var (
     # The 'kube-apiserver' must be valid cluster operator name (oc get clusteroperators).
 	%[1]s kube-apiserver --image=docker.io/foo/apiserver-operator:debug --operand-image docker.io/foo/apiserver:debug
 
+    # override the operator image and fail when the operator does not become available within 10 minutes
+	%[1]s kube-apiserver --image=docker.io/foo/apiserver-operator:debug --expect=Available=True,Degraded=False
+
+    # save the operator state before and after the override into the debug directory
+	%[1]s kube-apiserver --image=docker.io/foo/apiserver-operator:debug --snapshot=./debug
+
+    # override the operator image for 4 hours, 'oc operator-dev reap' will make the operator managed again after that
+	%[1]s kube-apiserver --image=docker.io/foo/apiserver-operator:debug --ttl=4h
+
+    # override the operand image and wait until the new revision is rolled out to all master nodes
+	%[1]s kube-apiserver --operand-image=docker.io/foo/hyperkube:debug --wait-revision
+
+    # restore the original operator when the new pods crash or the operator turns Degraded within 5 minutes
+	%[1]s kube-apiserver --image=docker.io/foo/apiserver-operator:debug --rollback-on-failure --health-timeout=5m
+
+    # override the operator image and apply the CVO manifests from the operator repository
+	%[1]s kube-apiserver --image=docker.io/foo/apiserver-operator:debug --manifests=./manifests
+
+    # override the operator with image from private repository, using the credentials from 'podman login'
+	%[1]s kube-apiserver --image=quay.io/me/apiserver-operator:debug --registry-auth
+
+    # override the operator deployment directly, this works also on clusters without the cluster version operator (eg. kind)
+	%[1]s deployment/foo-operator/foo-operator --image=docker.io/foo/foo-operator:debug
+
+    # override the image of the operator running as a daemonset
+	%[1]s daemonset/openshift-cluster-node-tuning-operator/tuned --image=docker.io/foo/cluster-node-tuning-operator:debug
+
+    # take over the operator overridden by another user
+	%[1]s kube-apiserver --image=docker.io/foo/apiserver-operator:debug --force
+
     # will make the openshift apiserver operator managed again
 	%[1]s openshift-apiserver --managed
 `
@@ -60,11 +151,12 @@ func NewCmdOperatorReplace(streams genericclioptions.IOStreams) *cobra.Command {
 	o := NewOverrideOptions(streams)
 
 	cmd := &cobra.Command{
-		Use:     "override <clusteroperator/name>",
+		Use:     "override <clusteroperator/name|deployment/namespace/name|daemonset/namespace/name|statefulset/namespace/name>",
 		Short:   "Override the target operator image",
 		Example: fmt.Sprintf(operatorOverrideExample, "oc operator-dev override"),
 		RunE: func(c *cobra.Command, args []string) error {
 			o.args = args
+			o.flags = c.Flags()
 			if err := o.Validate(); err != nil {
 				return err
 			}
@@ -80,38 +172,84 @@ func NewCmdOperatorReplace(streams genericclioptions.IOStreams) *cobra.Command {
 	cmd.Flags().StringVar(&o.verbosity, "verbosity", o.verbosity, "set the verbosity level for operator")
 	cmd.Flags().BoolVar(&o.managed, "managed", false, "set to true if you want cluster version operator to manage this operator")
 	cmd.Flags().StringVar(&o.deployment, "deployment", o.deployment, "custom deployment name")
+	cmd.Flags().BoolVar(&o.force, "force", o.force, "take over the operator lock even when it is held by another user")
+	cmd.Flags().DurationVar(&o.lockDuration, "lock-duration", o.lockDuration, "how long the operator lock is held for other users")
+	cmd.Flags().DurationVar(&o.ttl, "ttl", o.ttl, "revert the override after given time (requires 'operator-dev reap' to run periodically)")
+	cmd.Flags().StringVar(&o.snapshot, "snapshot", o.snapshot, "directory to save the operator state before and after the override into")
+	cmd.Flags().DurationVar(&o.rolloutTimeout, "rollout-timeout", o.rolloutTimeout, "how long to wait for the operator to roll out")
+	cmd.Flags().BoolVar(&o.waitRevision, "wait-revision", o.waitRevision, "wait for the new revision to roll out to all master nodes (static pod operators only)")
+	cmd.Flags().DurationVar(&o.revisionTimeout, "revision-timeout", o.revisionTimeout, "how long to wait for the new revision to roll out")
+	cmd.Flags().StringVar(&o.manifests, "manifests", o.manifests, "directory with the operator CVO manifests (0000_*.yaml) to apply, they are reverted with --managed")
+	cmd.Flags().StringToStringVar(&o.imageReferences, "image-reference", o.imageReferences, "image for the image-references tag used in the manifests (eg. --image-reference=hyperkube=docker.io/foo/hyperkube:debug)")
+	cmd.Flags().StringVar(&o.pullSecretFile, "pull-secret", o.pullSecretFile, "docker config file with the credentials to pull the override images, it is removed with --managed")
+	cmd.Flags().BoolVar(&o.registryAuth, "registry-auth", o.registryAuth, "pull the override images with the credentials from the local docker or podman auth file")
+	cmd.Flags().BoolVar(&o.skipPreflight, "skip-preflight", o.skipPreflight, "do not check the images exist and match the operator nodes architecture before the override")
+	cmd.Flags().BoolVar(&o.insecureRegistry, "insecure-registry", o.insecureRegistry, "access the image registry without TLS verification or over plain HTTP during preflight check")
+	cmd.Flags().BoolVar(&o.rollbackOnFailure, "rollback-on-failure", o.rollbackOnFailure, "restore the original operator when the new pods do not become ready or the operator turns Degraded")
+	cmd.Flags().DurationVar(&o.healthTimeout, "health-timeout", o.healthTimeout, "how long to watch the operator health when --rollback-on-failure is set")
+	cmd.Flags().StringVar(&o.crashLogs, "crash-logs", o.crashLogs, "directory to save the logs of failed pods into (default ~/.kube/operator-dev/crash-logs/<operator>-<timestamp>)")
+	cmd.Flags().StringVar(&o.expect, "expect", o.expect, "comma separated list of clusteroperator conditions expected after override (eg. Available=True,Degraded=False)")
+	cmd.Flags().DurationVar(&o.expectStable, "expect-stable", o.expectStable, "how long the expected conditions must hold")
+	cmd.Flags().DurationVar(&o.expectTimeout, "expect-timeout", o.expectTimeout, "how long to wait for the expected conditions")
 	o.configFlags.AddFlags(cmd.Flags())
 
 	return cmd
 }
 
-// getOperatorNamespace guess the namespace where the operator is being deployed.
-// TODO: This should not be necessary and we should have this information as related object in clusteroperator/foo
-func getOperatorNamespace(operatorName string) string {
-	operatorNamespace := strings.TrimPrefix(operatorName, "openshift-")
-	switch operatorName {
-	case "insights":
-		return "openshift-insights"
-	case "openshift-apiserver":
-		return "openshift-apiserver-operator"
-	default:
-		return "openshift-" + operatorNamespace + "-operator"
-	}
-}
-
-// getOperatorDeploymentName guess the deployment name of the operator.
-// TODO: This should not be necessary and we should have this information as related object in clusteroperator/foo
-func getOperatorDeploymentName(operatorName string) string {
-	return operatorName + "-operator"
-}
-
 func (o *OverrideOptions) Validate() error {
 	if len(o.args) == 0 {
-		return fmt.Errorf("clusteroperator/name must be specified")
+		return fmt.Errorf("clusteroperator/name or deployment/namespace/name must be specified")
+	}
+	target, err := operator.ParseTarget(o.args[0])
+	if err != nil {
+		return err
+	}
+	o.target = target
+	if !target.IsClusterOperator() {
+		if target.Kind != operator.DeploymentKind && len(o.manifests) > 0 {
+			return fmt.Errorf("--manifests can only be used with deployment target")
+		}
+		if len(o.deployment) > 0 {
+			return fmt.Errorf("--deployment can only be used with clusteroperator target")
+		}
+		if len(o.snapshot) > 0 || len(o.expect) > 0 {
+			return fmt.Errorf("--snapshot and --expect can only be used with clusteroperator target")
+		}
 	}
 	if len(o.image) != 0 && o.managed {
 		return fmt.Errorf("image must be empty when operator is managed")
 	}
+	if o.ttl != 0 && o.managed {
+		return fmt.Errorf("ttl can not be set when operator is managed")
+	}
+	if len(o.manifests) > 0 && o.managed {
+		return fmt.Errorf("--manifests can not be used when operator is managed, the applied manifests are reverted automatically")
+	}
+	if len(o.pullSecretFile) > 0 && o.registryAuth {
+		return fmt.Errorf("only one of --pull-secret or --registry-auth can be specified")
+	}
+	if (len(o.pullSecretFile) > 0 || o.registryAuth) && o.managed {
+		return fmt.Errorf("--pull-secret and --registry-auth can not be used when operator is managed, the pull secret is removed automatically")
+	}
+	if len(o.imageReferences) > 0 && len(o.manifests) == 0 {
+		return fmt.Errorf("--image-reference requires --manifests")
+	}
+	if o.rollbackOnFailure && o.managed {
+		return fmt.Errorf("--rollback-on-failure can not be used when operator is managed")
+	}
+	if o.waitRevision && !operator.IsStaticPodOperator(o.target.ClusterOperator) {
+		return fmt.Errorf("--wait-revision is only supported for static pod operators")
+	}
+	if len(o.expect) > 0 {
+		expectations, err := operator.ParseConditionExpectations(o.expect)
+		if err != nil {
+			return err
+		}
+		o.expectations = expectations
+		if o.expectStable > o.expectTimeout {
+			return fmt.Errorf("--expect-stable must not be longer than --expect-timeout")
+		}
+	}
 	return nil
 }
 
@@ -139,144 +277,677 @@ func (o *OverrideOptions) Complete() error {
 	}
 	o.kubeClient = kubeClient
 
+	// the deferred discovery mapper is reset by the manifests apply to find the custom resource definitions it creates
+	discoveryClient, err := o.configFlags.ToDiscoveryClient()
+	if err != nil {
+		return err
+	}
+	o.mapper = restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
+
 	return nil
 }
 
-func (o *OverrideOptions) Run() error {
-	// TODO: this should really come from discovery, but lets be lazy
-	clusterOperatorGvr := schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "clusteroperators"}
-	clusterVersionGvr := schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "clusterversions"}
+func (o *OverrideOptions) Run() (err error) {
+	kind := operator.DeploymentKind
+	var deployment *appsv1.Deployment
+	var template *corev1.PodTemplateSpec
+	// the conditions before the override, the health check fails only when the override makes the operator Degraded
+	var conditions []operator.Condition
+	if o.target.IsClusterOperator() {
+		// check if the cluster operator name is a valid operator
+		var clusterOperator *unstructured.Unstructured
+		clusterOperator, err = o.dynamicClient.Resource(operator.ClusterOperatorGVR).Get(o.target.ClusterOperator, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("operator %q is not valid operator: %v", o.target.ClusterOperator, err)
+		}
+		conditions = operator.GetConditions(clusterOperator)
 
-	// check if the cluster operator name is a valid operator
-	_, err := o.dynamicClient.Resource(clusterOperatorGvr).Get(o.args[0], metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("operator %q is not valid operator: %v", o.args[0], err)
+		// sanity check for existence of the deployment
+		deployment, err = operator.ResolveDeployment(o.kubeClient, o.target.ClusterOperator, o.deployment)
+		if err != nil {
+			return err
+		}
+		template = &deployment.Spec.Template
+	} else if o.target.Kind == operator.DeploymentKind {
+		deployment, err = o.kubeClient.AppsV1().Deployments(o.target.Namespace).Get(o.target.Name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to get deployment %s/%s: %v", o.target.Namespace, o.target.Name, err)
+		}
+		template = &deployment.Spec.Template
+	} else {
+		kind = o.target.Kind
+		if template, err = operator.GetPodTemplate(o.kubeClient, kind, o.target.Namespace, o.target.Name); err != nil {
+			return err
+		}
+	}
+	workloadNS, workloadName := o.target.Namespace, o.target.Name
+	if deployment != nil {
+		workloadNS, workloadName = deployment.Namespace, deployment.Name
 	}
 
-	// sanity check for existence of the deployment
-	deploymentName := getOperatorDeploymentName(o.args[0])
-	if len(o.deployment) > 0 {
-		deploymentName = o.deployment
+	// clusters without the cluster version operator (eg. kind) do not need the clusterversion override
+	if o.clusterVersion, err = operator.HasClusterVersion(o.dynamicClient); err != nil {
+		return err
 	}
-	deploymentNS := getOperatorNamespace(o.args[0])
-	if _, err := o.kubeClient.AppsV1().Deployments(deploymentNS).Get(deploymentName, metav1.GetOptions{}); errors.IsNotFound(err) {
-		deployments, err := o.kubeClient.AppsV1().Deployments(deploymentNS).List(metav1.ListOptions{})
-		if err != nil {
-			return fmt.Errorf("failed to get deployments in namespace %s: %v", deploymentNS, err)
+
+	// OLM reverts changes made to the deployment, so operators installed by OLM are overridden in their ClusterServiceVersion
+	var csv *unstructured.Unstructured
+	if deployment != nil {
+		if csv, err = olm.OwningCSV(o.dynamicClient, deployment); err != nil {
+			return err
 		}
-		if len(deployments.Items) == 1 {
-			deploymentName = deployments.Items[0].Name
-		} else {
-			return fmt.Errorf("deployment %s/%s not found. Maybe try --deployment for a custom name", deploymentNS, deploymentName)
+	}
+	if csv != nil && len(o.manifests) > 0 {
+		return fmt.Errorf("--manifests can not be used for operator installed by OLM clusterserviceversion %s/%s", csv.GetNamespace(), csv.GetName())
+	}
+
+	user, err := identity.CurrentUser(o.dynamicClient)
+	if err != nil {
+		return err
+	}
+	o.user = user
+
+	if len(o.pullSecretFile) > 0 || o.registryAuth {
+		if o.pullSecret, err = o.loadPullSecret(); err != nil {
+			return err
 		}
-	} else if err != nil {
-		return fmt.Errorf("unable to get deployment  %s/%s: %v", deploymentNS, deploymentName, err)
 	}
 
-	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
-		version, err := o.dynamicClient.Resource(clusterVersionGvr).Get("version", metav1.GetOptions{})
-		if err != nil {
+	// a wrong image is caught before the operator is unmanaged, instead of ending in ImagePullBackOff
+	if !o.managed && !o.skipPreflight {
+		if err := o.preflight(template.Spec.NodeSelector); err != nil {
 			return err
 		}
+	}
 
-		// replace or append override
-		overrides, _, err := unstructured.NestedSlice(version.Object, "spec", "overrides")
-		found := false
-		for _, x := range overrides {
-			override, ok := x.(map[string]interface{})
-			if !ok {
-				continue // ignore
-			}
+	// the changes are made as a transaction, so a failure or interrupt does not leave the operator half overridden
+	ctx, cancel := transaction.WithSignals(context.Background())
+	defer cancel()
+	tx := transaction.New(o.Out)
+
+	// the lock prevents other users from overriding the same operator, it is released when the operator is managed again
+	var expires time.Time
+	if o.ttl > 0 && !o.managed {
+		expires = time.Now().Add(o.ttl)
+	}
+	if err := tx.Run(ctx, lock.Step(o.kubeClient, o.target.ID(), lock.Options{
+		Holder:    o.user,
+		Duration:  o.lockDuration,
+		Force:     o.force,
+		Kind:      kind,
+		Namespace: workloadNS,
+		Name:      workloadName,
+		Expires:   expires,
+	})); err != nil {
+		return err
+	}
 
-			kind, _, _ := unstructured.NestedString(override, "kind")
-			group, _, _ := unstructured.NestedString(override, "group")
-			ns, _, _ := unstructured.NestedString(override, "namespace")
-			name, _, _ := unstructured.NestedString(override, "name")
+	// every change made to the cluster is recorded, including the failed ones
+	defer func() {
+		o.recordAudit(workloadNS, workloadName, operatorImage(template), err)
+	}()
 
-			if kind == "Deployment" && group == "apps/v1" && ns == deploymentNS && name == deploymentName {
-				found = true
-				unstructured.SetNestedField(override, !o.managed, "unmanaged")
-				break
-			}
+	// undo the changes already made when the override fails or is interrupted
+	defer func() {
+		if err == nil || tx.Empty() {
+			return
 		}
-		if !found {
-			overrides = append(overrides, map[string]interface{}{
-				"group":     "apps/v1",
-				"kind":      "Deployment",
-				"namespace": deploymentNS,
-				"name":      deploymentName,
-				"unmanaged": !o.managed,
-			})
-			unstructured.SetNestedField(version.Object, overrides, "spec", "overrides")
+		o.printOut("-> Override of operator %q failed (%v), rolling back ...\n", o.target.ID(), err)
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			err = fmt.Errorf("%v (%v)", err, rollbackErr)
+			return
 		}
+		err = fmt.Errorf("%v (changes were rolled back)", err)
+	}()
 
-		_, err = o.dynamicClient.Resource(clusterVersionGvr).Update(version, metav1.UpdateOptions{})
-		return err
-	}); err != nil {
-		return fmt.Errorf("failed to patch clusterversion/version: %v", err)
+	// remember the revision before the override, so the new revision can be recognized
+	var startRevision int64
+	if o.waitRevision && !o.managed {
+		status, err := operator.GetRevisionStatus(o.dynamicClient, o.target.ClusterOperator)
+		if err != nil {
+			return err
+		}
+		startRevision = status.LatestAvailableRevision
 	}
 
-	// if --managed is used, patch the clusterversion to unmanaged: false and exit
+	if len(o.snapshot) > 0 {
+		s := &snapshot.Snapshot{
+			DynamicClient:       o.dynamicClient,
+			Mapper:              o.mapper,
+			OperatorName:        o.target.ClusterOperator,
+			DeploymentNamespace: workloadNS,
+			DeploymentName:      workloadName,
+		}
+		if err := s.Capture(filepath.Join(o.snapshot, "before")); err != nil {
+			return fmt.Errorf("failed to capture snapshot: %v", err)
+		}
+		// the after state is captured even when the override fails (before it is rolled back), as that is when it is most useful
+		defer func() {
+			if snapshotErr := o.finishSnapshot(s); snapshotErr != nil && err == nil {
+				err = snapshotErr
+			}
+		}()
+	}
+
+	// the cluster version operator does not manage the deployments created by OLM
+	if csv == nil && o.clusterVersion && !o.managed {
+		if err := tx.Run(ctx, operator.UnmanagedStep(o.dynamicClient, operator.WorkloadOverride(kind, workloadNS, workloadName))); err != nil {
+			return err
+		}
+	}
+
+	// if --managed is used, revert the override and let the cluster version operator manage the operator again
 	if o.managed {
-		o.printOut("-> Operator %q now managed ...\n", deploymentName)
+		tx.Commit()
+		if err := revert.Override(o.dynamicClient, o.kubeClient, o.target.ID(), kind, workloadNS, workloadName, o.printOut); err != nil {
+			return err
+		}
+		if err := lock.Release(o.kubeClient, o.target.ID(), o.user, true); err != nil {
+			return fmt.Errorf("failed to release operator lock: %v", err)
+		}
+		if !o.clusterVersion && csv == nil {
+			o.printOut("-> Operator %q lock released, there is no cluster version operator to restore the %s ...\n", workloadName, strings.ToLower(kind))
+			return nil
+		}
+		o.printOut("-> Operator %q now managed ...\n", workloadName)
 		return nil
 	}
 
-	o.printOut("-> Operator %q is not managed ...\n", deploymentName)
+	switch {
+	case !o.clusterVersion && csv == nil:
+		o.printOut("-> Cluster has no cluster version operator, overriding %s ...\n", operator.WorkloadString(kind, workloadNS, workloadName))
+	case csv == nil:
+		o.printOut("-> Operator %q is not managed ...\n", workloadName)
+	default:
+		o.printOut("-> Operator %q is installed by OLM, overriding clusterserviceversion %s/%s ...\n", workloadName, csv.GetNamespace(), csv.GetName())
+	}
+	if o.ttl > 0 {
+		o.printOut("-> Operator %q will be managed again in %s (after %s) ...\n", workloadName, o.ttl, time.Now().Add(o.ttl).Format(time.RFC3339))
+	}
 
 	// In some case CVO will take time to reconcile new config, so give it 1s for starter
 	// TODO: The ClusterVersion operator should really reflect the current state in it's status
-	time.Sleep(1 * time.Second)
+	if csv == nil && o.clusterVersion {
+		if err := tx.Run(ctx, transaction.Step{
+			Name: "wait for cluster version operator",
+			Do: func(ctx context.Context) error {
+				return transaction.Sleep(ctx, 1*time.Second)
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	if o.pullSecret != nil {
+		if err := o.createPullSecret(ctx, tx, workloadNS); err != nil {
+			return err
+		}
+	}
+
+	var applied []*manifests.Applied
+	if len(o.manifests) > 0 {
+		if applied, err = o.applyManifests(ctx, tx, deployment); err != nil {
+			return err
+		}
+	}
 
-	// update the operator deployment with provided image
+	// update the operator workload with provided image
 	// TODO: verify the operator image was really changed
-	operandUpdated := false
-	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
-		operatorDeployment, err := o.kubeClient.AppsV1().Deployments(deploymentNS).Get(deploymentName, metav1.GetOptions{})
+	var csvState *olm.State
+	if csv != nil {
+		if csvState, err = o.overrideCSV(ctx, tx, csv, workloadName); err != nil {
+			return err
+		}
+	} else {
+		var previousTemplate *corev1.PodTemplateSpec
+		if err := tx.Run(ctx, transaction.Step{
+			Name: "operator " + strings.ToLower(kind),
+			Do: func(ctx context.Context) error {
+				previous, err := operator.UpdatePodTemplate(o.kubeClient, kind, workloadNS, workloadName, func(template *corev1.PodTemplateSpec) error {
+					return o.updatePodSpec(&template.Spec)
+				})
+				previousTemplate = previous
+				return err
+			},
+			Undo: func() error {
+				_, err := operator.UpdatePodTemplate(o.kubeClient, kind, workloadNS, workloadName, func(template *corev1.PodTemplateSpec) error {
+					*template = *previousTemplate
+					return nil
+				})
+				return err
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	// the new pods must stay healthy for the whole window, otherwise the override is rolled back
+	if o.rollbackOnFailure {
+		o.printOut("-> Watching operator %q health for %s ...\n", o.target.ID(), o.healthTimeout)
+		if err := tx.Run(ctx, transaction.Step{
+			Name: "health check",
+			Do: func(ctx context.Context) error {
+				err := operator.WatchWorkloadHealth(ctx, o.dynamicClient, o.kubeClient, o.target.ClusterOperator, conditions, kind, workloadNS, workloadName, o.healthTimeout)
+				if err != nil && ctx.Err() == nil {
+					o.saveCrashLogs(kind, workloadNS, workloadName)
+				}
+				return err
+			},
+		}); err != nil {
+			return err
+		}
+		o.printOut("-> Operator %q is healthy\n", o.target.ID())
+	}
+
+	// the override is rolled back when its state can not be saved, as it could not be reverted with --managed
+	if len(applied) > 0 {
+		if err := manifests.SaveState(o.kubeClient, o.target.ID(), applied); err != nil {
+			return fmt.Errorf("unable to save the applied manifests: %v", err)
+		}
+	}
+	if csvState != nil {
+		if err := olm.SaveState(o.kubeClient, o.target.ID(), csvState); err != nil {
+			return fmt.Errorf("unable to save the clusterserviceversion: %v", err)
+		}
+	}
+
+	// the override is done, a failed rollout below is reported but not rolled back
+	tx.Commit()
+	cancel()
+
+	if len(o.image) > 0 {
+		o.printOut("-> Operator %q image is now %q  ...\n", workloadName, o.image)
+	}
+	if len(o.operand) > 0 {
+		o.printOut("-> Operand image is now %q  ...\n", o.operand)
+	}
+
+	o.printOut("-> Waiting for %s to roll out ...\n", operator.WorkloadString(kind, workloadNS, workloadName))
+	if err := operator.WaitForRollout(o.kubeClient, kind, workloadNS, workloadName, o.rolloutTimeout); err != nil {
+		return err
+	}
+	o.printOut("-> Operator %q rolled out\n", workloadName)
+
+	if o.waitRevision {
+		// the operand image is in the static pod manifest, the operator image is used by the sidecar containers
+		image := o.operand
+		if len(image) == 0 {
+			image = o.image
+		}
+		o.printOut("-> Waiting for operator %q to roll out new revision ...\n", o.target.ID())
+		revision, err := operator.WaitForRevision(o.dynamicClient, o.kubeClient, o.target.ClusterOperator, startRevision, image, o.revisionTimeout, func(status *operator.RevisionStatus) {
+			o.printOut("   %s\n", status)
+		})
 		if err != nil {
-			return fmt.Errorf("unable to get deployment: %v", err)
+			return err
 		}
-		for i := range operatorDeployment.Spec.Template.Spec.Containers {
-			if len(o.image) > 0 {
-				operatorDeployment.Spec.Template.Spec.Containers[i].Image = o.image
-			}
+		o.printOut("-> Revision %d with image %q rolled out to all nodes\n", revision, image)
+	}
+
+	if len(o.expectations) > 0 {
+		o.printOut("-> Waiting for operator %q conditions %s to hold for %s ...\n", o.target.ID(), o.expect, o.expectStable)
+		if err := operator.WaitForConditions(o.dynamicClient, o.target.ClusterOperator, o.expectations, o.expectStable, o.expectTimeout); err != nil {
+			return err
+		}
+		o.printOut("-> Operator %q conditions are stable\n", o.target.ID())
+	}
 
-			if len(o.verbosity) > 0 {
-				operatorDeployment.Spec.Template.Spec.Containers[i].Args = append(operatorDeployment.Spec.Template.Spec.Containers[i].Args, fmt.Sprintf("-v=%s", o.verbosity))
+	return nil
+}
+
+// getOperatorNamespace guess the namespace where the operator is being deployed.
+func getOperatorNamespace(operatorName string) string {
+	return operator.GetOperatorNamespace(operatorName)
+}
+
+// updatePodSpec applies the operator image, operand image, verbosity and pull secret overrides to the operator pod spec.
+func (o *OverrideOptions) updatePodSpec(spec *corev1.PodSpec) error {
+	if len(o.image) > 0 {
+		operator.SetOperatorImage(spec, o.image)
+	}
+	operandUpdated := false
+	for i := range spec.Containers {
+		if len(o.verbosity) > 0 {
+			spec.Containers[i].Args = append(spec.Containers[i].Args, fmt.Sprintf("-v=%s", o.verbosity))
+		}
+
+		for j, ev := range spec.Containers[i].Env {
+			if ev.Name == "IMAGE" && len(o.operand) > 0 {
+				operandUpdated = true
+				spec.Containers[i].Env[j].Value = o.operand
 			}
+		}
+	}
+	if o.pullSecret != nil {
+		operator.AddImagePullSecret(spec, operator.PullSecretName)
+	}
+	if len(o.operand) > 0 && !operandUpdated {
+		return fmt.Errorf("no IMAGE env var found in the deployment")
+	}
+	return nil
+}
 
-			for j, ev := range operatorDeployment.Spec.Template.Spec.Containers[i].Env {
-				if ev.Name == "OPERATOR_IMAGE" && len(o.image) > 0 {
-					operatorDeployment.Spec.Template.Spec.Containers[i].Env[j].Value = o.image
+// overrideCSV changes the operator deployment in the ClusterServiceVersion install strategy, OLM then rolls out the
+// deployment. The returned state are the fields before the override, used to revert the ClusterServiceVersion.
+func (o *OverrideOptions) overrideCSV(ctx context.Context, tx *transaction.Transaction, csv *unstructured.Unstructured, workloadName string) (*olm.State, error) {
+	csvs := o.dynamicClient.Resource(olm.ClusterServiceVersionGVR).Namespace(csv.GetNamespace())
+	var previous *olm.State
+	err := tx.Run(ctx, transaction.Step{
+		Name: "operator clusterserviceversion",
+		Do: func(ctx context.Context) error {
+			return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+				current, err := csvs.Get(csv.GetName(), metav1.GetOptions{})
+				if err != nil {
+					return fmt.Errorf("unable to get clusterserviceversion: %v", err)
 				}
-			}
+				if previous == nil {
+					previous = olm.NewState(current)
+				}
+				if err := olm.UpdateDeployment(current, workloadName, o.updatePodSpec); err != nil {
+					return err
+				}
+				_, err = csvs.Update(current, metav1.UpdateOptions{})
+				return err
+			})
+		},
+		Undo: func() error {
+			return olm.Restore(o.dynamicClient, previous)
+		},
+	})
+	return previous, err
+}
+
+// finishSnapshot captures the state after the override and writes the summary diff.
+func (o *OverrideOptions) finishSnapshot(s *snapshot.Snapshot) error {
+	if err := s.Capture(filepath.Join(o.snapshot, "after")); err != nil {
+		return fmt.Errorf("failed to capture snapshot: %v", err)
+	}
+	summary, err := os.Create(filepath.Join(o.snapshot, "summary.diff"))
+	if err != nil {
+		return err
+	}
+	defer summary.Close()
+	if err := snapshot.WriteSummary(filepath.Join(o.snapshot, "before"), filepath.Join(o.snapshot, "after"), summary); err != nil {
+		return err
+	}
+	o.printOut("-> Snapshot saved to %q\n", o.snapshot)
+	return nil
+}
+
+// overrideImages returns all images used by the override.
+func (o *OverrideOptions) overrideImages() []string {
+	images := []string{}
+	for _, image := range []string{o.image, o.operand} {
+		if len(image) > 0 {
+			images = append(images, image)
+		}
+	}
+	for _, image := range o.imageReferences {
+		images = append(images, image)
+	}
+	return images
+}
 
-			for j, ev := range operatorDeployment.Spec.Template.Spec.Containers[i].Env {
-				if ev.Name == "IMAGE" && len(o.operand) > 0 {
-					operandUpdated = true
-					operatorDeployment.Spec.Template.Spec.Containers[i].Env[j].Value = o.operand
+// loadPullSecret reads the credentials for the override images. Only the credentials for the registries of the override
+// images are taken from the local auth file, so the other credentials are not copied into the cluster.
+func (o *OverrideOptions) loadPullSecret() (*registry.DockerConfig, error) {
+	file := o.pullSecretFile
+	if o.registryAuth {
+		localFile, err := registry.LocalAuthFile()
+		if err != nil {
+			return nil, err
+		}
+		file = localFile
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	config, err := registry.ParseDockerConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if !o.registryAuth {
+		return config, nil
+	}
+	var registries []string
+	for _, image := range o.overrideImages() {
+		ref, err := registry.ParseReference(image)
+		if err != nil {
+			return nil, err
+		}
+		registries = append(registries, ref.Registry)
+	}
+	config = config.Filter(registries)
+	if len(config.Auths) == 0 {
+		return nil, fmt.Errorf("%s has no credentials for %s", file, strings.Join(registries, ", "))
+	}
+	return config, nil
+}
+
+// createPullSecret creates or updates the pull secret in the operator namespace.
+func (o *OverrideOptions) createPullSecret(ctx context.Context, tx *transaction.Transaction, namespace string) error {
+	data, err := json.Marshal(o.pullSecret)
+	if err != nil {
+		return err
+	}
+	secrets := o.kubeClient.CoreV1().Secrets(namespace)
+	var previous *corev1.Secret
+	return tx.Run(ctx, transaction.Step{
+		Name: "pull secret",
+		Do: func(ctx context.Context) error {
+			secret, err := secrets.Get(operator.PullSecretName, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				_, err = secrets.Create(&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: operator.PullSecretName, Namespace: namespace, Labels: map[string]string{operator.PullSecretLabel: "true"}},
+					Type:       corev1.SecretTypeDockerConfigJson,
+					Data:       map[string][]byte{corev1.DockerConfigJsonKey: data},
+				})
+				return err
+			}
+			if err != nil {
+				return err
+			}
+			if !operator.IsOverridePullSecret(secret) {
+				return fmt.Errorf("secret %s/%s already exists and was not created by override", namespace, operator.PullSecretName)
+			}
+			previous = secret.DeepCopy()
+			secret.Data = map[string][]byte{corev1.DockerConfigJsonKey: data}
+			_, err = secrets.Update(secret)
+			return err
+		},
+		Undo: func() error {
+			if previous == nil {
+				err := secrets.Delete(operator.PullSecretName, &metav1.DeleteOptions{})
+				if errors.IsNotFound(err) {
+					return nil
 				}
+				return err
 			}
+			return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+				secret, err := secrets.Get(operator.PullSecretName, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				secret.Data = previous.Data
+				_, err = secrets.Update(secret)
+				return err
+			})
+		},
+	})
+}
+
+// preflight checks all override images can be pulled with the cluster global pull secret and are available for the
+// architectures of the nodes matching the operator node selector.
+func (o *OverrideOptions) preflight(nodeSelector map[string]string) error {
+	images := o.overrideImages()
+	if len(images) == 0 {
+		return nil
+	}
+
+	pullSecret, err := registry.GlobalPullSecret(o.kubeClient)
+	if err != nil {
+		return fmt.Errorf("unable to read the global pull secret: %v", err)
+	}
+	if pullSecret == nil {
+		fmt.Fprintf(o.ErrOut, "warning: unable to read openshift-config/pull-secret, the images are checked without credentials\n")
+	}
+	architectures, err := registry.NodeArchitectures(o.kubeClient, nodeSelector)
+	if err != nil {
+		return fmt.Errorf("unable to get the nodes architecture: %v", err)
+	}
+	if len(architectures) == 0 {
+		fmt.Fprintf(o.ErrOut, "warning: no nodes found for the operator, the images architecture is not checked\n")
+	}
+
+	client := registry.NewClient(pullSecret.Merge(o.pullSecret), o.insecureRegistry)
+	for _, image := range images {
+		if err := client.Preflight(image, architectures); err != nil {
+			return fmt.Errorf("preflight check failed: %v (use --skip-preflight to override anyway)", err)
 		}
-		for i := range operatorDeployment.Spec.Template.Spec.InitContainers {
-			operatorDeployment.Spec.Template.Spec.Containers[i].Image = o.image
+		if len(architectures) == 0 {
+			o.printOut("-> Image %q is available\n", image)
+			continue
 		}
-		_, err = o.kubeClient.AppsV1().Deployments(deploymentNS).Update(operatorDeployment)
-		return err
-	}); err != nil {
-		return err
+		o.printOut("-> Image %q is available for %s\n", image, strings.Join(architectures, ", "))
 	}
+	return nil
+}
 
-	if len(o.image) > 0 {
-		o.printOut("-> Operator %q image is now %q  ...\n", deploymentName, o.image)
+// applyManifests applies the manifests in the order the cluster version operator applies them. Every object is a transaction
+// step, so it is reverted when the override fails.
+func (o *OverrideOptions) applyManifests(ctx context.Context, tx *transaction.Transaction, deployment *appsv1.Deployment) ([]*manifests.Applied, error) {
+	// only the manifests the cluster version operator applies in this cluster, other variants of the deployment are skipped
+	profile, err := operator.ClusterProfile(o.kubeClient)
+	if err != nil {
+		return nil, err
 	}
-	if len(o.operand) > 0 {
-		if operandUpdated {
-			o.printOut("-> Operand image is now %q  ...\n", o.operand)
-		} else {
-			return fmt.Errorf("no IMAGE env var found in the deployment")
+	featureSet, err := operator.FeatureSet(o.dynamicClient)
+	if err != nil {
+		return nil, err
+	}
+	documents, err := manifests.ReadDocuments(o.manifests, profile, featureSet)
+	if err != nil {
+		return nil, err
+	}
+	references, err := manifests.ReadImageReferences(o.manifests)
+	if err != nil {
+		return nil, err
+	}
+	manifestDeployment, err := manifests.FindDeployment(documents, deployment.Namespace, deployment.Name)
+	if err != nil {
+		return nil, err
+	}
+	replacements, err := manifests.ResolveImages(references, o.imageReferences, o.image, manifestDeployment, deployment)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := manifests.Decode(documents, references, replacements)
+	if err != nil {
+		return nil, err
+	}
+
+	// the cluster version operator must not revert the objects changed by the manifests
+	var components []operator.ComponentOverride
+	for _, m := range objects {
+		components = append(components, operator.ObjectOverride(m.Object))
+	}
+	var wasUnmanaged []operator.ComponentOverride
+	if o.clusterVersion {
+		if err := tx.Run(ctx, transaction.Step{
+			Name: "clusterversion overrides for manifests",
+			Do: func(ctx context.Context) error {
+				unmanaged, err := operator.UnmanagedComponents(o.dynamicClient, components...)
+				if err != nil {
+					return err
+				}
+				wasUnmanaged = unmanaged
+				return operator.SetUnmanagedComponents(o.dynamicClient, true, components...)
+			},
+			Undo: func() error {
+				var managed []operator.ComponentOverride
+				for _, component := range components {
+					if !containsComponent(wasUnmanaged, component) {
+						managed = append(managed, component)
+					}
+				}
+				return operator.SetUnmanagedComponents(o.dynamicClient, false, managed...)
+			},
+		}); err != nil {
+			return nil, err
 		}
 	}
 
-	return nil
+	var applied []*manifests.Applied
+	for _, m := range objects {
+		m := m
+		var a *manifests.Applied
+		if err := tx.Run(ctx, transaction.Step{
+			Name: fmt.Sprintf("%s %q from %s", m.Object.GetKind(), m.Object.GetName(), m.File),
+			Do: func(ctx context.Context) error {
+				var err error
+				a, err = manifests.Apply(o.dynamicClient, o.mapper, m.Object)
+				return err
+			},
+			Undo: func() error {
+				return manifests.Revert(o.dynamicClient, a)
+			},
+		}); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+		o.printOut("-> Applied %s from %s\n", a, m.File)
+	}
+	return applied, nil
+}
+
+func containsComponent(components []operator.ComponentOverride, component operator.ComponentOverride) bool {
+	for _, c := range components {
+		if c == component {
+			return true
+		}
+	}
+	return false
+}
+
+// saveCrashLogs keeps the logs of the failed pods before the deployment is rolled back. Failures are only reported.
+func (o *OverrideOptions) saveCrashLogs(kind, namespace, name string) {
+	dir := o.crashLogs
+	if len(dir) == 0 {
+		dir = filepath.Join(homedir.HomeDir(), ".kube", "operator-dev", "crash-logs", fmt.Sprintf("%s-%s", o.target.ID(), time.Now().Format("20060102-150405")))
+	}
+	files, err := operator.SavePodLogs(o.kubeClient, kind, namespace, name, dir)
+	if err != nil {
+		fmt.Fprintf(o.ErrOut, "warning: unable to save logs of failed pods: %v\n", err)
+		return
+	}
+	if len(files) > 0 {
+		o.printOut("-> Logs of failed pods saved to %q\n", dir)
+	}
+}
+
+// operatorImage returns the image of the first operator container.
+func operatorImage(template *corev1.PodTemplateSpec) string {
+	if len(template.Spec.Containers) == 0 {
+		return ""
+	}
+	return template.Spec.Containers[0].Image
+}
+
+// recordAudit appends the override record to the audit log.
+func (o *OverrideOptions) recordAudit(namespace, name, oldImage string, runErr error) {
+	record := audit.Record{
+		User:      o.user,
+		Command:   "override",
+		Operator:  o.target.ID(),
+		Namespace: namespace,
+		Name:      name,
+		OldImage:  oldImage,
+		NewImage:  oldImage,
+		Flags:     audit.ChangedFlags(o.flags),
+	}
+	if len(o.image) > 0 {
+		record.NewImage = o.image
+	}
+	audit.Log(o.kubeClient, o.dynamicClient, o.ErrOut, record, runErr)
 }
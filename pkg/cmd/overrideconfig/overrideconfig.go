@@ -0,0 +1,396 @@
+package overrideconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/audit"
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+)
+
+// OverrideConfigOptions provides information required to override
+// the operator configuration config maps and secrets
+type OverrideConfigOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	flags       *pflag.FlagSet
+
+	args       []string
+	configMap  string
+	secret     string
+	fromFiles  []string
+	deployment string
+	restore    bool
+	timeout    time.Duration
+
+	files map[string][]byte
+
+	dynamicClient dynamic.Interface
+	kubeClient    kubernetes.Interface
+
+	genericclioptions.IOStreams
+}
+
+// NewOverrideConfigOptions provides an instance of OverrideConfigOptions with default values
+func NewOverrideConfigOptions(streams genericclioptions.IOStreams) *OverrideConfigOptions {
+	return &OverrideConfigOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		timeout:     5 * time.Minute,
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	operatorOverrideConfigExample = `
+	# override-config will tell cluster version operator to stop managing the config map, replace the config.yaml key with the
+    # content of the local file and restart the operator when it mounts the config map.
+	%[1]s kube-apiserver --configmap=config --from-file=config.yaml=./config.yaml
+
+    # override a secret in the namespace other than the operator namespace
+	%[1]s kube-apiserver --secret=serving-cert --from-file=tls.crt=./tls.crt -n openshift-kube-apiserver
+
+    # restore the original data and make the config map managed again
+	%[1]s kube-apiserver --configmap=config --restore
+`
+)
+
+func NewCmdOperatorOverrideConfig(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewOverrideConfigOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "override-config <clusteroperator/name>",
+		Short:   "Override the target operator configuration config map or secret",
+		Example: fmt.Sprintf(operatorOverrideConfigExample, "oc operator-dev override-config"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			o.flags = c.Flags()
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.configMap, "configmap", o.configMap, "name of the config map to override")
+	cmd.Flags().StringVar(&o.secret, "secret", o.secret, "name of the secret to override")
+	cmd.Flags().StringArrayVar(&o.fromFiles, "from-file", o.fromFiles, "key=path of the file to set the key to (the key defaults to the file name)")
+	cmd.Flags().StringVar(&o.deployment, "deployment", o.deployment, "custom operator deployment name")
+	cmd.Flags().BoolVar(&o.restore, "restore", o.restore, "restore the original data and make the object managed again")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", o.timeout, "how long to wait for the operator to restart")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// parseFromFile parses the key=path or path, in which case the key is the file name.
+func parseFromFile(value string) (string, string, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) == 1 {
+		return filepath.Base(parts[0]), parts[0], nil
+	}
+	if len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", fmt.Errorf("invalid --from-file %q, must be in key=path format", value)
+	}
+	return parts[0], parts[1], nil
+}
+
+func (o *OverrideConfigOptions) Validate() error {
+	if len(o.args) == 0 {
+		return fmt.Errorf("clusteroperator/name must be specified")
+	}
+	if (len(o.configMap) == 0) == (len(o.secret) == 0) {
+		return fmt.Errorf("exactly one of --configmap or --secret must be specified")
+	}
+	if o.restore && len(o.fromFiles) > 0 {
+		return fmt.Errorf("--from-file can not be used with --restore")
+	}
+	if !o.restore && len(o.fromFiles) == 0 {
+		return fmt.Errorf("at least one --from-file must be specified")
+	}
+	o.files = map[string][]byte{}
+	for _, value := range o.fromFiles {
+		key, path, err := parseFromFile(value)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		o.files[key] = data
+	}
+	return nil
+}
+
+func (o *OverrideConfigOptions) printOut(message string, objs ...interface{}) {
+	if _, err := fmt.Fprintf(o.Out, message, objs...); err != nil {
+		panic(err)
+	}
+}
+
+func (o *OverrideConfigOptions) Complete() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.dynamicClient = dynamicClient
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.kubeClient = kubeClient
+
+	return nil
+}
+
+// kind returns the kind and name of the overridden object.
+func (o *OverrideConfigOptions) kind() (string, string) {
+	if len(o.configMap) > 0 {
+		return "ConfigMap", o.configMap
+	}
+	return "Secret", o.secret
+}
+
+// namespace returns the namespace given by --namespace flag or the operator namespace.
+func (o *OverrideConfigOptions) namespace() string {
+	if o.configFlags.Namespace != nil && len(*o.configFlags.Namespace) > 0 {
+		return *o.configFlags.Namespace
+	}
+	return operator.GetOperatorNamespace(o.args[0])
+}
+
+// backupName returns the name of the object in the plugin namespace holding the original data.
+func backupName(kind, namespace, name string) string {
+	return strings.ToLower(kind) + "-" + namespace + "-" + name
+}
+
+func (o *OverrideConfigOptions) Run() error {
+	if _, err := o.dynamicClient.Resource(operator.ClusterOperatorGVR).Get(o.args[0], metav1.GetOptions{}); err != nil {
+		return fmt.Errorf("operator %q is not valid operator: %v", o.args[0], err)
+	}
+	deployment, err := operator.ResolveDeployment(o.kubeClient, o.args[0], o.deployment)
+	if err != nil {
+		return err
+	}
+
+	kind, name := o.kind()
+	namespace := o.namespace()
+	component := operator.ComponentOverride{Kind: kind, Namespace: namespace, Name: name}
+
+	if o.restore {
+		original, err := o.getData(kind, operator.PluginNamespace, backupName(kind, namespace, name))
+		if err != nil {
+			return fmt.Errorf("unable to get original data of %s %s/%s: %v", kind, namespace, name, err)
+		}
+		if err := o.updateData(kind, namespace, name, original, true); err != nil {
+			return err
+		}
+		if err := o.deleteBackup(kind, namespace, name); err != nil {
+			return err
+		}
+		if err := operator.SetUnmanaged(o.dynamicClient, component, false); err != nil {
+			return err
+		}
+		o.printOut("-> %s %s/%s restored and now managed ...\n", kind, namespace, name)
+	} else {
+		if err := o.backup(kind, namespace, name); err != nil {
+			return err
+		}
+		if err := operator.SetUnmanaged(o.dynamicClient, component, true); err != nil {
+			return err
+		}
+		o.printOut("-> %s %s/%s is not managed ...\n", kind, namespace, name)
+		if err := o.updateData(kind, namespace, name, o.files, false); err != nil {
+			return err
+		}
+		o.printOut("-> %s %s/%s keys %s updated ...\n", kind, namespace, name, strings.Join(sortedKeys(o.files), ", "))
+	}
+	o.recordAudit(namespace, name)
+
+	if deployment.Namespace != namespace || !mountsObject(deployment.Spec.Template.Spec, kind, name) {
+		return nil
+	}
+	o.printOut("-> Restarting operator %q to pick up the change ...\n", deployment.Name)
+	return operator.RestartDeploymentPods(o.kubeClient, deployment, o.timeout)
+}
+
+// getData returns the data of the config map or secret.
+func (o *OverrideConfigOptions) getData(kind, namespace, name string) (map[string][]byte, error) {
+	if kind == "Secret" {
+		secret, err := o.kubeClient.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return secret.Data, nil
+	}
+	configMap, err := o.kubeClient.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	data := map[string][]byte{}
+	for k, v := range configMap.Data {
+		data[k] = []byte(v)
+	}
+	return data, nil
+}
+
+// updateData sets the keys of the config map or secret. When replace is set, keys not present in data are removed.
+func (o *OverrideConfigOptions) updateData(kind, namespace, name string, data map[string][]byte, replace bool) error {
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if kind == "Secret" {
+			secret, err := o.kubeClient.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if replace || secret.Data == nil {
+				secret.Data = map[string][]byte{}
+			}
+			for k, v := range data {
+				secret.Data[k] = v
+			}
+			_, err = o.kubeClient.CoreV1().Secrets(namespace).Update(secret)
+			return err
+		}
+		configMap, err := o.kubeClient.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if replace || configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		for k, v := range data {
+			configMap.Data[k] = string(v)
+		}
+		_, err = o.kubeClient.CoreV1().ConfigMaps(namespace).Update(configMap)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update %s %s/%s: %v", kind, namespace, name, err)
+	}
+	return nil
+}
+
+// backup copies the original data into the plugin namespace, unless it was already saved by previous override.
+func (o *OverrideConfigOptions) backup(kind, namespace, name string) error {
+	data, err := o.getData(kind, namespace, name)
+	if err != nil {
+		return fmt.Errorf("unable to get %s %s/%s: %v", kind, namespace, name, err)
+	}
+	if err := operator.EnsurePluginNamespace(o.kubeClient); err != nil {
+		return err
+	}
+	objectMeta := metav1.ObjectMeta{Name: backupName(kind, namespace, name), Namespace: operator.PluginNamespace}
+	if kind == "Secret" {
+		_, err = o.kubeClient.CoreV1().Secrets(operator.PluginNamespace).Create(&corev1.Secret{ObjectMeta: objectMeta, Data: data})
+	} else {
+		configMap := &corev1.ConfigMap{ObjectMeta: objectMeta, Data: map[string]string{}}
+		for k, v := range data {
+			configMap.Data[k] = string(v)
+		}
+		_, err = o.kubeClient.CoreV1().ConfigMaps(operator.PluginNamespace).Create(configMap)
+	}
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("unable to save original data of %s %s/%s: %v", kind, namespace, name, err)
+	}
+	return nil
+}
+
+func (o *OverrideConfigOptions) deleteBackup(kind, namespace, name string) error {
+	var err error
+	if kind == "Secret" {
+		err = o.kubeClient.CoreV1().Secrets(operator.PluginNamespace).Delete(backupName(kind, namespace, name), &metav1.DeleteOptions{})
+	} else {
+		err = o.kubeClient.CoreV1().ConfigMaps(operator.PluginNamespace).Delete(backupName(kind, namespace, name), &metav1.DeleteOptions{})
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// mountsObject returns true when the pod uses the config map or secret as a volume or environment variables.
+func mountsObject(spec corev1.PodSpec, kind, name string) bool {
+	for _, v := range spec.Volumes {
+		if kind == "ConfigMap" && v.ConfigMap != nil && v.ConfigMap.Name == name {
+			return true
+		}
+		if kind == "Secret" && v.Secret != nil && v.Secret.SecretName == name {
+			return true
+		}
+		if v.Projected == nil {
+			continue
+		}
+		for _, source := range v.Projected.Sources {
+			if kind == "ConfigMap" && source.ConfigMap != nil && source.ConfigMap.Name == name {
+				return true
+			}
+			if kind == "Secret" && source.Secret != nil && source.Secret.Name == name {
+				return true
+			}
+		}
+	}
+	for _, c := range append(spec.InitContainers, spec.Containers...) {
+		for _, envFrom := range c.EnvFrom {
+			if kind == "ConfigMap" && envFrom.ConfigMapRef != nil && envFrom.ConfigMapRef.Name == name {
+				return true
+			}
+			if kind == "Secret" && envFrom.SecretRef != nil && envFrom.SecretRef.Name == name {
+				return true
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if kind == "ConfigMap" && env.ValueFrom.ConfigMapKeyRef != nil && env.ValueFrom.ConfigMapKeyRef.Name == name {
+				return true
+			}
+			if kind == "Secret" && env.ValueFrom.SecretKeyRef != nil && env.ValueFrom.SecretKeyRef.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sortedKeys(data map[string][]byte) []string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (o *OverrideConfigOptions) recordAudit(namespace, name string) {
+	audit.Log(o.kubeClient, o.dynamicClient, o.ErrOut, audit.Record{
+		Command:   "override-config",
+		Operator:  o.args[0],
+		Namespace: namespace,
+		Name:      name,
+		Flags:     audit.ChangedFlags(o.flags),
+	}, nil)
+}
@@ -0,0 +1,69 @@
+package overrideconfig
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_parseFromFile(t *testing.T) {
+	tests := map[string]struct {
+		key         string
+		path        string
+		expectError bool
+	}{
+		"config.yaml=./local.yaml": {key: "config.yaml", path: "./local.yaml"},
+		"/tmp/config.yaml":         {key: "config.yaml", path: "/tmp/config.yaml"},
+		"=./local.yaml":            {expectError: true},
+	}
+
+	for value, test := range tests {
+		t.Run(value, func(t *testing.T) {
+			key, path, err := parseFromFile(value)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if key != test.key || path != test.path {
+				t.Errorf("expected %s=%s, got %s=%s", test.key, test.path, key, path)
+			}
+		})
+	}
+}
+
+func Test_mountsObject(t *testing.T) {
+	spec := corev1.PodSpec{
+		Volumes: []corev1.Volume{
+			{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}}}},
+			{Name: "serving-cert", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "serving-cert"}}},
+		},
+		Containers: []corev1.Container{{
+			Name: "operator",
+			Env: []corev1.EnvVar{{Name: "IMAGE", ValueFrom: &corev1.EnvVarSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "images"}, Key: "image"},
+			}}},
+		}},
+	}
+
+	tests := []struct {
+		kind     string
+		name     string
+		expected bool
+	}{
+		{kind: "ConfigMap", name: "config", expected: true},
+		{kind: "Secret", name: "serving-cert", expected: true},
+		{kind: "ConfigMap", name: "images", expected: true},
+		{kind: "Secret", name: "config"},
+		{kind: "ConfigMap", name: "other"},
+	}
+	for _, test := range tests {
+		if got := mountsObject(spec, test.kind, test.name); got != test.expected {
+			t.Errorf("%s/%s: expected %v, got %v", test.kind, test.name, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package pause
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/audit"
+	"github.com/mfojtik/operator-dev-plugin/pkg/identity"
+	"github.com/mfojtik/operator-dev-plugin/pkg/lock"
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+)
+
+// pausedReplicasAnnotation records the number of operator replicas before the operator was paused.
+const pausedReplicasAnnotation = "operator-dev.openshift.io/paused-replicas"
+
+// PauseOptions provides information required to pause
+// or resume the operator
+type PauseOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	flags       *pflag.FlagSet
+
+	args         []string
+	resume       bool
+	deployment   string
+	force        bool
+	lockDuration time.Duration
+	timeout      time.Duration
+
+	dynamicClient dynamic.Interface
+	kubeClient    kubernetes.Interface
+
+	genericclioptions.IOStreams
+}
+
+// NewPauseOptions provides an instance of PauseOptions with default values
+func NewPauseOptions(streams genericclioptions.IOStreams) *PauseOptions {
+	return &PauseOptions{
+		configFlags:  genericclioptions.NewConfigFlags(true),
+		lockDuration: lock.DefaultDuration,
+		timeout:      5 * time.Minute,
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	operatorPauseExample = `
+	# stop the kube-apiserver operator, so the operands can be edited by hand
+	%[1]s kube-apiserver
+
+    # stop the operator with custom deployment name
+	%[1]s kube-apiserver --deployment=custom-operator
+`
+
+	operatorResumeExample = `
+	# start the paused kube-apiserver operator again
+	%[1]s kube-apiserver
+`
+)
+
+func NewCmdOperatorPause(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewPauseOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "pause <clusteroperator/name>",
+		Short:   "Scale the operator down to zero and stop the cluster version operator from managing it",
+		Example: fmt.Sprintf(operatorPauseExample, "oc operator-dev pause"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			o.flags = c.Flags()
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.deployment, "deployment", o.deployment, "custom deployment name")
+	cmd.Flags().BoolVar(&o.force, "force", o.force, "take over the operator lock even when it is held by another user")
+	cmd.Flags().DurationVar(&o.lockDuration, "lock-duration", o.lockDuration, "how long the operator lock is held for other users")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", o.timeout, "how long to wait for the operator pods to terminate")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func NewCmdOperatorResume(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewPauseOptions(streams)
+	o.resume = true
+
+	cmd := &cobra.Command{
+		Use:     "resume <clusteroperator/name>",
+		Short:   "Restore the replicas of a paused operator and let the cluster version operator manage it again",
+		Example: fmt.Sprintf(operatorResumeExample, "oc operator-dev resume"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			o.flags = c.Flags()
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.deployment, "deployment", o.deployment, "custom deployment name")
+	cmd.Flags().BoolVar(&o.force, "force", o.force, "resume the operator even when it was paused by another user")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", o.timeout, "how long to wait for the operator rollout")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *PauseOptions) Validate() error {
+	if len(o.args) == 0 {
+		return fmt.Errorf("clusteroperator/name must be specified")
+	}
+	return nil
+}
+
+func (o *PauseOptions) printOut(message string, objs ...interface{}) {
+	if _, err := fmt.Fprintf(o.Out, message, objs...); err != nil {
+		panic(err)
+	}
+}
+
+func (o *PauseOptions) Complete() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.dynamicClient = dynamicClient
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.kubeClient = kubeClient
+
+	return nil
+}
+
+// pauseDeployment saves the current replicas and scales the deployment to zero.
+// The saved replicas are kept when the deployment is already paused.
+func pauseDeployment(deployment *appsv1.Deployment) {
+	if _, paused := deployment.Annotations[pausedReplicasAnnotation]; !paused {
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+		if deployment.Annotations == nil {
+			deployment.Annotations = map[string]string{}
+		}
+		deployment.Annotations[pausedReplicasAnnotation] = strconv.Itoa(int(replicas))
+	}
+	zero := int32(0)
+	deployment.Spec.Replicas = &zero
+}
+
+// resumeDeployment restores the saved replicas. It returns false when the deployment is not paused.
+func resumeDeployment(deployment *appsv1.Deployment) (bool, error) {
+	value, paused := deployment.Annotations[pausedReplicasAnnotation]
+	if !paused {
+		return false, nil
+	}
+	replicas, err := strconv.Atoi(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s annotation %q: %v", pausedReplicasAnnotation, value, err)
+	}
+	restored := int32(replicas)
+	deployment.Spec.Replicas = &restored
+	delete(deployment.Annotations, pausedReplicasAnnotation)
+	return true, nil
+}
+
+func (o *PauseOptions) Run() (err error) {
+	if _, err := o.dynamicClient.Resource(operator.ClusterOperatorGVR).Get(o.args[0], metav1.GetOptions{}); err != nil {
+		return fmt.Errorf("operator %q is not valid operator: %v", o.args[0], err)
+	}
+	deployment, err := operator.ResolveDeployment(o.kubeClient, o.args[0], o.deployment)
+	if err != nil {
+		return err
+	}
+	deploymentNS, deploymentName := deployment.Namespace, deployment.Name
+
+	user, err := identity.CurrentUser(o.dynamicClient)
+	if err != nil {
+		return err
+	}
+
+	// every change made to the cluster is recorded, including the failed ones
+	defer func() {
+		o.recordAudit(user, deploymentNS, deploymentName, err)
+	}()
+
+	if o.resume {
+		return o.resumeOperator(user, deploymentNS, deploymentName)
+	}
+	return o.pauseOperator(user, deploymentNS, deploymentName)
+}
+
+func (o *PauseOptions) pauseOperator(user, namespace, name string) error {
+	// resume must not release the lock and make the operator managed when it was overridden before the pause
+	previous, err := lock.Get(o.kubeClient, o.args[0])
+	if err != nil {
+		return err
+	}
+	paused := "lock"
+	if previous != nil {
+		if value, ok := previous.Annotations[lock.PausedAnnotation]; ok {
+			paused = value
+		} else {
+			paused = "override"
+		}
+	}
+	if !o.force {
+		if err := lock.CheckWorkload(o.kubeClient, o.args[0], user, operator.DeploymentKind, namespace, name); err != nil {
+			return err
+		}
+	}
+	if err := lock.Acquire(o.kubeClient, o.args[0], user, o.lockDuration, o.force); err != nil {
+		return err
+	}
+	if err := lock.Annotate(o.kubeClient, o.args[0], map[string]string{
+		lock.DeploymentNamespaceAnnotation: namespace,
+		lock.DeploymentNameAnnotation:      name,
+		lock.PausedAnnotation:              paused,
+	}); err != nil {
+		return fmt.Errorf("failed to update operator lock: %v", err)
+	}
+
+	// the cluster version operator must stop managing the deployment first, otherwise it scales the operator up again
+	if err := operator.SetUnmanaged(o.dynamicClient, operator.DeploymentOverride(namespace, name), true); err != nil {
+		return err
+	}
+	o.printOut("-> Operator %q is not managed ...\n", name)
+
+	var replicas string
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		deployment, err := o.kubeClient.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		pauseDeployment(deployment)
+		replicas = deployment.Annotations[pausedReplicasAnnotation]
+		_, err = o.kubeClient.AppsV1().Deployments(namespace).Update(deployment)
+		return err
+	}); err != nil {
+		return fmt.Errorf("unable to scale deployment %s/%s: %v", namespace, name, err)
+	}
+	o.printOut("-> Operator %q scaled down to 0 (from %s replicas), waiting for pods to terminate ...\n", name, replicas)
+
+	err = wait.PollImmediate(2*time.Second, o.timeout, func() (bool, error) {
+		deployment, err := o.kubeClient.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		return deployment.Status.ObservedGeneration >= deployment.Generation && deployment.Status.Replicas == 0, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("deployment %s/%s pods did not terminate within %s", namespace, name, o.timeout)
+	}
+	if err != nil {
+		return err
+	}
+	o.printOut("-> Operator %q paused, run 'oc operator-dev resume %s' to start it again\n", name, o.args[0])
+	return nil
+}
+
+func (o *PauseOptions) resumeOperator(user, namespace, name string) error {
+	// the operator paused by somebody else is left alone, the same as the operator overridden by somebody else
+	if !o.force {
+		if err := lock.CheckHolder(o.kubeClient, o.args[0], user); err != nil {
+			return err
+		}
+	}
+	lease, err := lock.Get(o.kubeClient, o.args[0])
+	if err != nil {
+		return err
+	}
+	if lease != nil && len(lease.Annotations[lock.PausedAnnotation]) == 0 {
+		return fmt.Errorf("operator %q is overridden and was not paused, use 'oc operator-dev override %s --managed' to make it managed", name, o.args[0])
+	}
+
+	resumed := false
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		deployment, err := o.kubeClient.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if resumed, err = resumeDeployment(deployment); err != nil || !resumed {
+			return err
+		}
+		_, err = o.kubeClient.AppsV1().Deployments(namespace).Update(deployment)
+		return err
+	}); err != nil {
+		return fmt.Errorf("unable to scale deployment %s/%s: %v", namespace, name, err)
+	}
+	if resumed {
+		o.printOut("-> Operator %q replicas restored ...\n", name)
+	} else {
+		o.printOut("-> Operator %q is not paused, nothing to restore ...\n", name)
+	}
+
+	switch {
+	case lease == nil:
+		// no lock means the operator was not paused by pause, the cluster version operator overrides are left alone
+		o.printOut("-> Operator %q is not locked, waiting for rollout ...\n", name)
+	case lease.Annotations[lock.PausedAnnotation] == "override":
+		if err := lock.Annotate(o.kubeClient, o.args[0], map[string]string{lock.PausedAnnotation: ""}); err != nil {
+			return fmt.Errorf("failed to update operator lock: %v", err)
+		}
+		o.printOut("-> Operator %q is still overridden, waiting for rollout ...\n", name)
+	default:
+		// pause took the lock and added the cluster version operator override
+		if err := operator.SetUnmanaged(o.dynamicClient, operator.DeploymentOverride(namespace, name), false); err != nil {
+			return err
+		}
+		if err := lock.Release(o.kubeClient, o.args[0], user, o.force); err != nil {
+			return fmt.Errorf("failed to release operator lock: %v", err)
+		}
+		o.printOut("-> Operator %q now managed, waiting for rollout ...\n", name)
+	}
+
+	return operator.WaitForDeploymentRollout(o.kubeClient, namespace, name, o.timeout)
+}
+
+func (o *PauseOptions) recordAudit(user, namespace, name string, runErr error) {
+	command := "pause"
+	if o.resume {
+		command = "resume"
+	}
+	audit.Log(o.kubeClient, o.dynamicClient, o.ErrOut, audit.Record{User: user, Command: command, Operator: o.args[0], Namespace: namespace, Name: name, Flags: audit.ChangedFlags(o.flags)}, runErr)
+}
@@ -0,0 +1,33 @@
+package pause
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestPauseResumeDeployment(t *testing.T) {
+	three := int32(3)
+	deployment := &appsv1.Deployment{}
+	deployment.Spec.Replicas = &three
+
+	pauseDeployment(deployment)
+	pauseDeployment(deployment)
+	if *deployment.Spec.Replicas != 0 {
+		t.Errorf("expected deployment to be scaled to 0, got %d", *deployment.Spec.Replicas)
+	}
+	if replicas := deployment.Annotations[pausedReplicasAnnotation]; replicas != "3" {
+		t.Errorf("expected pausing twice to keep the original replicas, got %q", replicas)
+	}
+
+	resumed, err := resumeDeployment(deployment)
+	if err != nil || !resumed {
+		t.Fatalf("expected deployment to be resumed, got %v, %v", resumed, err)
+	}
+	if *deployment.Spec.Replicas != 3 {
+		t.Errorf("expected replicas to be restored to 3, got %d", *deployment.Spec.Replicas)
+	}
+	if resumed, err := resumeDeployment(deployment); err != nil || resumed {
+		t.Errorf("expected resuming twice to be no-op, got %v, %v", resumed, err)
+	}
+}
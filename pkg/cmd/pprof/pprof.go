@@ -0,0 +1,183 @@
+package pprof
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+	"github.com/mfojtik/operator-dev-plugin/pkg/portforward"
+)
+
+// PprofOptions provides information required to collect
+// a profile of the operator
+type PprofOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	args       []string
+	profile    string
+	seconds    int
+	output     string
+	deployment string
+
+	restConfig    *rest.Config
+	dynamicClient dynamic.Interface
+	kubeClient    kubernetes.Interface
+
+	genericclioptions.IOStreams
+}
+
+// NewPprofOptions provides an instance of PprofOptions with default values
+func NewPprofOptions(streams genericclioptions.IOStreams) *PprofOptions {
+	return &PprofOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		profile:     "heap",
+		seconds:     30,
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	operatorPprofExample = `
+	# save the heap profile of the kube-apiserver operator
+	%[1]s kube-apiserver --profile=heap
+
+    # save 60 seconds CPU profile of the operator into given file and inspect it
+	%[1]s kube-apiserver --profile=cpu --seconds=60 --output=cpu.pprof
+	go tool pprof cpu.pprof
+`
+)
+
+func NewCmdOperatorPprof(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewPprofOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "pprof <clusteroperator/name>",
+		Short:   "Collect the operator profile via port-forward",
+		Example: fmt.Sprintf(operatorPprofExample, "oc operator-dev pprof"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.profile, "profile", o.profile, "profile to collect (heap, cpu, goroutine)")
+	cmd.Flags().IntVar(&o.seconds, "seconds", o.seconds, "duration of the CPU profile in seconds")
+	cmd.Flags().StringVarP(&o.output, "output", "o", o.output, "file to save the profile to (default <operator>-<profile>-<timestamp>.pprof)")
+	cmd.Flags().StringVar(&o.deployment, "deployment", o.deployment, "custom deployment name")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// profilePath returns the pprof endpoint path for the profile.
+func profilePath(profile string, seconds int) (string, error) {
+	switch profile {
+	case "heap", "goroutine":
+		return "/debug/pprof/" + profile, nil
+	case "cpu":
+		return fmt.Sprintf("/debug/pprof/profile?seconds=%d", seconds), nil
+	default:
+		return "", fmt.Errorf("invalid profile %q, must be one of heap, cpu, goroutine", profile)
+	}
+}
+
+func (o *PprofOptions) Validate() error {
+	if len(o.args) == 0 {
+		return fmt.Errorf("clusteroperator/name must be specified")
+	}
+	if o.seconds <= 0 {
+		return fmt.Errorf("--seconds must be positive")
+	}
+	_, err := profilePath(o.profile, o.seconds)
+	return err
+}
+
+func (o *PprofOptions) printOut(message string, objs ...interface{}) {
+	if _, err := fmt.Fprintf(o.Out, message, objs...); err != nil {
+		panic(err)
+	}
+}
+
+func (o *PprofOptions) Complete() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	o.restConfig = restConfig
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.dynamicClient = dynamicClient
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.kubeClient = kubeClient
+
+	if len(o.output) == 0 {
+		o.output = fmt.Sprintf("%s-%s-%s.pprof", o.args[0], o.profile, time.Now().Format("20060102-150405"))
+	}
+
+	return nil
+}
+
+func (o *PprofOptions) Run() error {
+	if _, err := o.dynamicClient.Resource(operator.ClusterOperatorGVR).Get(o.args[0], metav1.GetOptions{}); err != nil {
+		return fmt.Errorf("operator %q is not valid operator: %v", o.args[0], err)
+	}
+	deployment, err := operator.ResolveDeployment(o.kubeClient, o.args[0], o.deployment)
+	if err != nil {
+		return err
+	}
+	token, err := portforward.BearerToken(o.restConfig)
+	if err != nil {
+		return err
+	}
+	endpoint, err := portforward.FindOperatorEndpoint(o.kubeClient, deployment)
+	if err != nil {
+		return err
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	localPort, err := portforward.Forward(o.restConfig, o.kubeClient, endpoint, stopCh)
+	if err != nil {
+		return err
+	}
+
+	path, _ := profilePath(o.profile, o.seconds)
+	timeout := time.Minute
+	if o.profile == "cpu" {
+		o.printOut("-> Collecting %ds CPU profile from pod %s/%s (port %d) ...\n", o.seconds, endpoint.Namespace, endpoint.Pod, endpoint.Port)
+		timeout += time.Duration(o.seconds) * time.Second
+	} else {
+		o.printOut("-> Collecting %s profile from pod %s/%s (port %d) ...\n", o.profile, endpoint.Namespace, endpoint.Pod, endpoint.Port)
+	}
+	profile, err := portforward.Get(endpoint.Scheme, localPort, path, token, timeout)
+	if err != nil {
+		return fmt.Errorf("unable to collect %s profile: %v", o.profile, err)
+	}
+	if err := ioutil.WriteFile(o.output, profile, 0644); err != nil {
+		return err
+	}
+	o.printOut("-> Profile saved to %s (use 'go tool pprof %s' to inspect it)\n", o.output, o.output)
+	return nil
+}
@@ -0,0 +1,363 @@
+package reap
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/audit"
+	"github.com/mfojtik/operator-dev-plugin/pkg/lock"
+	"github.com/mfojtik/operator-dev-plugin/pkg/manifests"
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+	"github.com/mfojtik/operator-dev-plugin/pkg/revert"
+	overridestate "github.com/mfojtik/operator-dev-plugin/pkg/state"
+)
+
+// reaperName is the name of the service account, roles, role bindings and cron job running the reaper in cluster.
+const reaperName = "operator-dev-reaper"
+
+var cronJobGVR = schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"}
+
+// ReapOptions provides information required to revert
+// the expired operator overrides
+type ReapOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	dryRun   bool
+	install  bool
+	image    string
+	schedule string
+
+	dynamicClient dynamic.Interface
+	kubeClient    kubernetes.Interface
+
+	genericclioptions.IOStreams
+}
+
+// NewReapOptions provides an instance of ReapOptions with default values
+func NewReapOptions(streams genericclioptions.IOStreams) *ReapOptions {
+	return &ReapOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		schedule:    "*/10 * * * *",
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	operatorReapExample = `
+	# show the time left for every operator override
+	%[1]s --dry-run
+
+    # make all operators with expired override managed again
+	%[1]s
+
+    # install a cron job that makes operators with expired override managed again every 10 minutes
+	%[1]s --install --image=quay.io/foo/operator-dev-plugin:latest
+`
+)
+
+func NewCmdOperatorReap(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewReapOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "reap",
+		Short:   "Make operators with expired override managed again",
+		Example: fmt.Sprintf(operatorReapExample, "oc operator-dev reap"),
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", o.dryRun, "only show the overrides and the time they have left")
+	cmd.Flags().BoolVar(&o.install, "install", o.install, "install a cron job that runs the reaper periodically in the cluster")
+	cmd.Flags().StringVar(&o.image, "image", o.image, "image with the plugin binary used by the cron job")
+	cmd.Flags().StringVar(&o.schedule, "schedule", o.schedule, "cron job schedule")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *ReapOptions) Validate() error {
+	if o.install && len(o.image) == 0 {
+		return fmt.Errorf("image must be specified when installing the cron job")
+	}
+	return nil
+}
+
+func (o *ReapOptions) printOut(message string, objs ...interface{}) {
+	if _, err := fmt.Fprintf(o.Out, message, objs...); err != nil {
+		panic(err)
+	}
+}
+
+func (o *ReapOptions) Complete() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.dynamicClient = dynamicClient
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.kubeClient = kubeClient
+
+	return nil
+}
+
+func (o *ReapOptions) Run() error {
+	if o.install {
+		return o.installCronJob()
+	}
+
+	leases, err := lock.List(o.kubeClient)
+	if err != nil {
+		return fmt.Errorf("unable to list operator overrides: %v", err)
+	}
+
+	// one broken override must not keep the others from being reaped
+	var errs []error
+	for i := range leases {
+		lease := &leases[i]
+		holder := "unknown"
+		if lease.Spec.HolderIdentity != nil {
+			holder = *lease.Spec.HolderIdentity
+		}
+		expiry, ok := lock.OverrideExpiry(lease)
+		if !ok {
+			o.printOut("-> Operator %q overridden by %q has no TTL\n", lease.Name, holder)
+			continue
+		}
+		if left := time.Until(expiry); left > 0 {
+			o.printOut("-> Operator %q overridden by %q expires in %s\n", lease.Name, holder, left.Round(time.Second))
+			continue
+		}
+		if o.dryRun {
+			o.printOut("-> Operator %q overridden by %q expired %s ago\n", lease.Name, holder, time.Since(expiry).Round(time.Second))
+			continue
+		}
+		if err := o.reap(lease.Name, lease.Annotations[lock.WorkloadKindAnnotation], lease.Annotations[lock.DeploymentNamespaceAnnotation], lease.Annotations[lock.DeploymentNameAnnotation]); err != nil {
+			errs = append(errs, fmt.Errorf("failed to make operator %q managed: %v", lease.Name, err))
+			continue
+		}
+		o.printOut("-> Operator %q overridden by %q expired, now managed ...\n", lease.Name, holder)
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// reap reverts the override the same way 'override --managed' does and releases the lock.
+func (o *ReapOptions) reap(operatorName, kind, deploymentNS, deploymentName string) error {
+	if len(deploymentNS) == 0 || len(deploymentName) == 0 {
+		return fmt.Errorf("operator lock does not record the overridden deployment")
+	}
+	if len(kind) == 0 {
+		kind = operator.DeploymentKind
+	}
+	if err := revert.Override(o.dynamicClient, o.kubeClient, operatorName, kind, deploymentNS, deploymentName, o.printOut); err != nil {
+		// the cron job is granted the rights for the manifests applied when it was installed
+		if state, _ := manifests.LoadState(o.kubeClient, operatorName); len(state) > 0 {
+			return fmt.Errorf("%v (overrides with --manifests applied after 'reap --install' need the cron job to be installed again)", err)
+		}
+		return err
+	}
+	if err := lock.Release(o.kubeClient, operatorName, "", true); err != nil {
+		return err
+	}
+
+	audit.Log(o.kubeClient, o.dynamicClient, o.ErrOut, audit.Record{Command: "reap", Operator: operatorName, Namespace: deploymentNS, Name: deploymentName}, nil)
+	return nil
+}
+
+// reaperClusterRules allow the reaper to revert the clusterversion overrides, the ClusterServiceVersions changed by the
+// override and to remove the override pull secret from the operator workload. The rights to revert the applied manifests
+// are added by clusterRules.
+var reaperClusterRules = []rbacv1.PolicyRule{
+	{APIGroups: []string{"config.openshift.io"}, Resources: []string{"clusterversions"}, Verbs: []string{"get", "update", "patch"}},
+	{APIGroups: []string{"operators.coreos.com"}, Resources: []string{"clusterserviceversions"}, Verbs: []string{"get", "update"}},
+	{APIGroups: []string{"apps"}, Resources: []string{"deployments", "daemonsets", "statefulsets"}, Verbs: []string{"get", "update"}},
+	{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{operator.PullSecretName}, Verbs: []string{"get", "delete"}},
+	{APIGroups: []string{""}, Resources: []string{"namespaces"}, ResourceNames: []string{operator.PluginNamespace}, Verbs: []string{"get"}},
+}
+
+// clusterRules adds the rules needed to revert the manifests applied by the overrides to the reaper cluster rules.
+func clusterRules(applied []*manifests.Applied) []rbacv1.PolicyRule {
+	rules := append([]rbacv1.PolicyRule{}, reaperClusterRules...)
+	return append(rules, manifests.RevertRules(applied)...)
+}
+
+// appliedManifests returns the objects applied from the manifests by all overrides in the cluster.
+func (o *ReapOptions) appliedManifests() ([]*manifests.Applied, error) {
+	operators, err := overridestate.List(o.kubeClient)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list override state: %v", err)
+	}
+	var applied []*manifests.Applied
+	for _, operatorName := range operators {
+		state, err := manifests.LoadState(o.kubeClient, operatorName)
+		if err != nil {
+			return nil, err
+		}
+		applied = append(applied, state...)
+	}
+	return applied, nil
+}
+
+// reaperRules allow the reaper to release the operator leases and to update the audit log and the override state in the
+// plugin namespace.
+var reaperRules = []rbacv1.PolicyRule{
+	{APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, Verbs: []string{"get", "list", "update", "delete"}},
+	{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "create", "update", "delete"}},
+}
+
+// installRBAC gives the reaper service account only the permissions needed to revert the overrides, including the
+// manifests applied by the overrides in the cluster. Bindings created by older versions to cluster-admin are replaced, as
+// the role of a binding can not be changed.
+func (o *ReapOptions) installRBAC() error {
+	applied, err := o.appliedManifests()
+	if err != nil {
+		return err
+	}
+	clusterRoleRules := clusterRules(applied)
+
+	rbac := o.kubeClient.RbacV1()
+	subjects := []rbacv1.Subject{{Kind: "ServiceAccount", Name: reaperName, Namespace: operator.PluginNamespace}}
+
+	clusterRole, err := rbac.ClusterRoles().Get(reaperName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = rbac.ClusterRoles().Create(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: reaperName}, Rules: clusterRoleRules})
+	case err == nil:
+		clusterRole.Rules = clusterRoleRules
+		_, err = rbac.ClusterRoles().Update(clusterRole)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to install cluster role: %v", err)
+	}
+
+	role, err := rbac.Roles(operator.PluginNamespace).Get(reaperName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = rbac.Roles(operator.PluginNamespace).Create(&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: reaperName, Namespace: operator.PluginNamespace}, Rules: reaperRules})
+	case err == nil:
+		role.Rules = reaperRules
+		_, err = rbac.Roles(operator.PluginNamespace).Update(role)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to install role: %v", err)
+	}
+
+	clusterRoleRef := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: reaperName}
+	clusterRoleBinding, err := rbac.ClusterRoleBindings().Get(reaperName, metav1.GetOptions{})
+	if err == nil && clusterRoleBinding.RoleRef != clusterRoleRef {
+		if err := rbac.ClusterRoleBindings().Delete(reaperName, &metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("unable to replace cluster role binding: %v", err)
+		}
+		err = errors.NewNotFound(rbacv1.Resource("clusterrolebindings"), reaperName)
+	}
+	if errors.IsNotFound(err) {
+		_, err = rbac.ClusterRoleBindings().Create(&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: reaperName}, RoleRef: clusterRoleRef, Subjects: subjects})
+	}
+	if err != nil {
+		return fmt.Errorf("unable to install cluster role binding: %v", err)
+	}
+
+	_, err = rbac.RoleBindings(operator.PluginNamespace).Create(&rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: reaperName, Namespace: operator.PluginNamespace},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: reaperName},
+		Subjects:   subjects,
+	})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("unable to install role binding: %v", err)
+	}
+	return nil
+}
+
+// installCronJob installs a cron job running the reaper in the plugin namespace.
+func (o *ReapOptions) installCronJob() error {
+	if err := operator.EnsurePluginNamespace(o.kubeClient); err != nil {
+		return err
+	}
+
+	_, err := o.kubeClient.CoreV1().ServiceAccounts(operator.PluginNamespace).Create(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: reaperName, Namespace: operator.PluginNamespace},
+	})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("unable to create service account: %v", err)
+	}
+
+	if err := o.installRBAC(); err != nil {
+		return err
+	}
+
+	jobSpec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&batchv1.JobSpec{
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				ServiceAccountName: reaperName,
+				RestartPolicy:      corev1.RestartPolicyOnFailure,
+				Containers: []corev1.Container{{
+					Name:    "reaper",
+					Image:   o.image,
+					Command: []string{"kubectl-operator_dev", "reap"},
+					Env:     []corev1.EnvVar{{Name: "HOME", Value: "/tmp"}},
+				}},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	// the batch/v1 cron job is not in the vendored client, it is created through the dynamic client
+	spec := map[string]interface{}{
+		"schedule":          o.schedule,
+		"concurrencyPolicy": "Forbid",
+		"jobTemplate":       map[string]interface{}{"spec": jobSpec},
+	}
+	cronJobs := o.dynamicClient.Resource(cronJobGVR).Namespace(operator.PluginNamespace)
+	existing, err := cronJobs.Get(reaperName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = cronJobs.Create(&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "batch/v1",
+			"kind":       "CronJob",
+			"metadata":   map[string]interface{}{"name": reaperName, "namespace": operator.PluginNamespace},
+			"spec":       spec,
+		}}, metav1.CreateOptions{})
+	case err == nil:
+		if err = unstructured.SetNestedMap(existing.Object, spec, "spec"); err == nil {
+			_, err = cronJobs.Update(existing, metav1.UpdateOptions{})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to install cron job: %v", err)
+	}
+
+	o.printOut("-> Reaper cron job %s/%s installed with schedule %q ...\n", operator.PluginNamespace, reaperName, o.schedule)
+	return nil
+}
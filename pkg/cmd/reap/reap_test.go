@@ -0,0 +1,137 @@
+package reap
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/manifests"
+)
+
+// authorizingClient is a dynamic client allowing only the requests covered by the rules, like the reaper service account.
+type authorizingClient struct {
+	dynamic.Interface
+	rules   []rbacv1.PolicyRule
+	objects map[schema.GroupVersionResource]map[string]*unstructured.Unstructured
+}
+
+func (c *authorizingClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &authorizingResource{client: c, gvr: gvr}
+}
+
+func (c *authorizingClient) allowed(gvr schema.GroupVersionResource, verb string) bool {
+	for _, rule := range c.rules {
+		if contains(rule.APIGroups, gvr.Group) && contains(rule.Resources, gvr.Resource) && contains(rule.Verbs, verb) && len(rule.ResourceNames) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || v == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+type authorizingResource struct {
+	dynamic.NamespaceableResourceInterface
+	client    *authorizingClient
+	gvr       schema.GroupVersionResource
+	namespace string
+}
+
+func (r *authorizingResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &authorizingResource{client: r.client, gvr: r.gvr, namespace: namespace}
+}
+
+func (r *authorizingResource) authorize(verb, name string) error {
+	if !r.client.allowed(r.gvr, verb) {
+		return errors.NewForbidden(r.gvr.GroupResource(), name, nil)
+	}
+	return nil
+}
+
+func (r *authorizingResource) Get(name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if err := r.authorize("get", name); err != nil {
+		return nil, err
+	}
+	if obj, ok := r.client.objects[r.gvr][r.namespace+"/"+name]; ok {
+		return obj, nil
+	}
+	return nil, errors.NewNotFound(r.gvr.GroupResource(), name)
+}
+
+func (r *authorizingResource) Create(obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if err := r.authorize("create", obj.GetName()); err != nil {
+		return nil, err
+	}
+	r.client.objects[r.gvr][r.namespace+"/"+obj.GetName()] = obj
+	return obj, nil
+}
+
+func (r *authorizingResource) Update(obj *unstructured.Unstructured, options metav1.UpdateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if err := r.authorize("update", obj.GetName()); err != nil {
+		return nil, err
+	}
+	r.client.objects[r.gvr][r.namespace+"/"+obj.GetName()] = obj
+	return obj, nil
+}
+
+func (r *authorizingResource) Delete(name string, options *metav1.DeleteOptions, subresources ...string) error {
+	if err := r.authorize("delete", name); err != nil {
+		return err
+	}
+	delete(r.client.objects[r.gvr], r.namespace+"/"+name)
+	return nil
+}
+
+func TestReapManifests(t *testing.T) {
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	foos := schema.GroupVersionResource{Group: "example.openshift.io", Version: "v1", Resource: "foos"}
+	previous := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "config", "namespace": "openshift-foo"},
+		"data":       map[string]interface{}{"level": "Normal"},
+	}}
+	applied := []*manifests.Applied{
+		{GVR: configMaps, Namespace: "openshift-foo", Name: "config", Previous: previous},
+		{GVR: foos, Name: "cluster"},
+	}
+	newClient := func(rules []rbacv1.PolicyRule) *authorizingClient {
+		changed := previous.DeepCopy()
+		changed.Object["data"] = map[string]interface{}{"level": "Debug"}
+		return &authorizingClient{rules: rules, objects: map[schema.GroupVersionResource]map[string]*unstructured.Unstructured{
+			configMaps: {"openshift-foo/config": changed},
+			foos:       {"/cluster": {Object: map[string]interface{}{"metadata": map[string]interface{}{"name": "cluster"}}}},
+		}}
+	}
+
+	client := newClient(reaperClusterRules)
+	if err := manifests.Revert(client, applied[0]); !errors.IsForbidden(err) {
+		t.Errorf("expected the static reaper rules to forbid reverting the manifests, got %v", err)
+	}
+
+	client = newClient(clusterRules(applied))
+	for _, a := range applied {
+		if err := manifests.Revert(client, a); err != nil {
+			t.Fatalf("unexpected error reverting %s: %v", a, err)
+		}
+	}
+	level, _, _ := unstructured.NestedString(client.objects[configMaps]["openshift-foo/config"].Object, "data", "level")
+	if level != "Normal" {
+		t.Errorf("expected the config map to be reverted, got level %q", level)
+	}
+	if _, ok := client.objects[foos]["/cluster"]; ok {
+		t.Errorf("expected the created custom resource to be deleted")
+	}
+}
@@ -0,0 +1,178 @@
+package restart
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/audit"
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+)
+
+// restartedAtAnnotation is the pod template annotation used by 'kubectl rollout restart'.
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// RestartOptions provides information required to restart
+// the operator pods
+type RestartOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	flags       *pflag.FlagSet
+
+	args       []string
+	deployment string
+	timeout    time.Duration
+
+	dynamicClient dynamic.Interface
+	kubeClient    kubernetes.Interface
+
+	genericclioptions.IOStreams
+}
+
+// NewRestartOptions provides an instance of RestartOptions with default values
+func NewRestartOptions(streams genericclioptions.IOStreams) *RestartOptions {
+	return &RestartOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		timeout:     5 * time.Minute,
+
+		IOStreams: streams,
+	}
+}
+
+var (
+	operatorRestartExample = `
+	# restart the kube-apiserver operator and wait for the new pods
+	%[1]s kube-apiserver
+
+    # restart the operator with custom deployment name
+	%[1]s kube-apiserver --deployment=custom-operator
+`
+)
+
+func NewCmdOperatorRestart(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewRestartOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "restart <clusteroperator/name>",
+		Short:   "Restart the operator pods without changing the operator spec",
+		Example: fmt.Sprintf(operatorRestartExample, "oc operator-dev restart"),
+		RunE: func(c *cobra.Command, args []string) error {
+			o.args = args
+			o.flags = c.Flags()
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			return o.Run()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.deployment, "deployment", o.deployment, "custom deployment name")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", o.timeout, "how long to wait for the rollout")
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *RestartOptions) Validate() error {
+	if len(o.args) == 0 {
+		return fmt.Errorf("clusteroperator/name must be specified")
+	}
+	return nil
+}
+
+func (o *RestartOptions) printOut(message string, objs ...interface{}) {
+	if _, err := fmt.Fprintf(o.Out, message, objs...); err != nil {
+		panic(err)
+	}
+}
+
+func (o *RestartOptions) Complete() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.dynamicClient = dynamicClient
+
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.kubeClient = kubeClient
+
+	return nil
+}
+
+func (o *RestartOptions) Run() error {
+	if _, err := o.dynamicClient.Resource(operator.ClusterOperatorGVR).Get(o.args[0], metav1.GetOptions{}); err != nil {
+		return fmt.Errorf("operator %q is not valid operator: %v", o.args[0], err)
+	}
+	deployment, err := operator.ResolveDeployment(o.kubeClient, o.args[0], o.deployment)
+	if err != nil {
+		return err
+	}
+
+	unmanaged, err := operator.IsUnmanaged(o.dynamicClient, operator.DeploymentOverride(deployment.Namespace, deployment.Name))
+	if err != nil {
+		return err
+	}
+	if !unmanaged {
+		fmt.Fprintf(o.ErrOut, "warning: deployment %s/%s is managed by the cluster version operator, it will revert the %s annotation and roll out the operator again\n", deployment.Namespace, deployment.Name, restartedAtAnnotation)
+	}
+
+	restartedAt := time.Now()
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`, restartedAtAnnotation, restartedAt.Format(time.RFC3339))
+	if _, err := o.kubeClient.AppsV1().Deployments(deployment.Namespace).Patch(deployment.Name, types.StrategicMergePatchType, []byte(patch)); err != nil {
+		o.recordAudit(deployment.Namespace, deployment.Name, err)
+		return fmt.Errorf("unable to restart deployment %s/%s: %v", deployment.Namespace, deployment.Name, err)
+	}
+	o.printOut("-> Operator %q restarted, waiting for rollout ...\n", deployment.Name)
+
+	err = operator.WaitForDeploymentRollout(o.kubeClient, deployment.Namespace, deployment.Name, o.timeout)
+	o.recordAudit(deployment.Namespace, deployment.Name, err)
+	if err != nil {
+		return err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	pods, err := o.kubeClient.CoreV1().Pods(deployment.Namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return err
+	}
+	o.printOut("-> Operator %q is running in pods: %s\n", deployment.Name, strings.Join(newPodNames(pods.Items, restartedAt), ", "))
+	return nil
+}
+
+// newPodNames returns the names of running pods created after the restart.
+func newPodNames(pods []corev1.Pod, restartedAt time.Time) []string {
+	var names []string
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.CreationTimestamp.Time.Before(restartedAt.Truncate(time.Second)) {
+			continue
+		}
+		names = append(names, pod.Name)
+	}
+	return names
+}
+
+func (o *RestartOptions) recordAudit(namespace, name string, runErr error) {
+	audit.Log(o.kubeClient, o.dynamicClient, o.ErrOut, audit.Record{Command: "restart", Operator: o.args[0], Namespace: namespace, Name: name, Flags: audit.ChangedFlags(o.flags)}, runErr)
+}
@@ -0,0 +1,23 @@
+package restart
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_newPodNames(t *testing.T) {
+	restartedAt := time.Now()
+	deleted := metav1.NewTime(restartedAt)
+	pods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "old", CreationTimestamp: metav1.NewTime(restartedAt.Add(-time.Hour))}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "terminating", CreationTimestamp: metav1.NewTime(restartedAt), DeletionTimestamp: &deleted}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "new", CreationTimestamp: metav1.NewTime(restartedAt.Add(time.Second))}},
+	}
+	if names := newPodNames(pods, restartedAt); !reflect.DeepEqual(names, []string{"new"}) {
+		t.Errorf("expected only new pod, got %v", names)
+	}
+}
@@ -0,0 +1,42 @@
+package identity
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	// selfSubjectReviewVersions are tried in order, as older clusters only serve the alpha or beta version
+	selfSubjectReviewVersions = []string{"v1", "v1beta1", "v1alpha1"}
+
+	openshiftUsersGVR = schema.GroupVersionResource{Group: "user.openshift.io", Version: "v1", Resource: "users"}
+)
+
+// CurrentUser returns the name of the user the client is authenticated as (same as "oc whoami").
+// It uses the SelfSubjectReview API and falls back to the OpenShift "users/~" endpoint.
+func CurrentUser(client dynamic.Interface) (string, error) {
+	for _, version := range selfSubjectReviewVersions {
+		gvr := schema.GroupVersionResource{Group: "authentication.k8s.io", Version: version, Resource: "selfsubjectreviews"}
+		review := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": gvr.GroupVersion().String(),
+			"kind":       "SelfSubjectReview",
+		}}
+		result, err := client.Resource(gvr).Create(review, metav1.CreateOptions{})
+		if err != nil {
+			continue
+		}
+		if name, _, _ := unstructured.NestedString(result.Object, "status", "userInfo", "username"); len(name) > 0 {
+			return name, nil
+		}
+	}
+
+	user, err := client.Resource(openshiftUsersGVR).Get("~", metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("unable to determine current user: %v", err)
+	}
+	return user.GetName(), nil
+}
@@ -0,0 +1,312 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+	"github.com/mfojtik/operator-dev-plugin/pkg/transaction"
+)
+
+const (
+	// DefaultDuration is how long the lock is held when not renewed.
+	DefaultDuration = 24 * time.Hour
+
+	// DeploymentNamespaceAnnotation and DeploymentNameAnnotation record the deployment overridden by the lease holder.
+	DeploymentNamespaceAnnotation = "operator-dev.openshift.io/deployment-namespace"
+	DeploymentNameAnnotation      = "operator-dev.openshift.io/deployment-name"
+
+	// WorkloadKindAnnotation records the kind of the overridden workload when it is not a deployment.
+	WorkloadKindAnnotation = "operator-dev.openshift.io/workload-kind"
+
+	// PausedAnnotation is set while the operator is paused. It records whether pause took the lock ("lock"), or the
+	// operator was already overridden by the lock holder ("override"), so resume keeps the override.
+	PausedAnnotation = "operator-dev.openshift.io/paused"
+
+	// ExpiresAnnotation records the time (RFC3339) after which the override should be reverted.
+	ExpiresAnnotation = "operator-dev.openshift.io/expires"
+)
+
+// Options describe the operator lock taken by a transaction step.
+type Options struct {
+	Holder   string
+	Duration time.Duration
+	Force    bool
+
+	// Kind, Namespace and Name identify the workload locked through the operator lock. They are recorded in the lease
+	// annotations, so reap and history know what is locked and the workload can not be locked through another target.
+	Kind      string
+	Namespace string
+	Name      string
+
+	// Expires is the time after which reap reverts the override, zero means the override does not expire.
+	Expires time.Time
+}
+
+// Step returns the transaction step taking the operator lock for the workload. Undo puts back the lease as it was before.
+func Step(client kubernetes.Interface, operatorName string, options Options) transaction.Step {
+	var previous *coordinationv1.Lease
+	return transaction.Step{
+		Name: "operator lock",
+		Do: func(ctx context.Context) error {
+			lease, err := Get(client, operatorName)
+			if err != nil {
+				return err
+			}
+			previous = lease
+			if !options.Force {
+				if err := CheckWorkload(client, operatorName, options.Holder, options.Kind, options.Namespace, options.Name); err != nil {
+					return err
+				}
+			}
+			if err := Acquire(client, operatorName, options.Holder, options.Duration, options.Force); err != nil {
+				return err
+			}
+			if err := Annotate(client, operatorName, WorkloadAnnotations(options.Kind, options.Namespace, options.Name, options.Expires)); err != nil {
+				if restoreErr := Restore(client, operatorName, previous); restoreErr != nil {
+					return fmt.Errorf("%v (unable to restore the operator lock: %v)", err, restoreErr)
+				}
+				return fmt.Errorf("failed to update operator lock: %v", err)
+			}
+			return nil
+		},
+		Undo: func() error {
+			return Restore(client, operatorName, previous)
+		},
+	}
+}
+
+// WorkloadAnnotations returns the lease annotations recording the locked workload and the override expiry. The kind of
+// deployments and the zero expiry are not recorded.
+func WorkloadAnnotations(kind, namespace, name string, expires time.Time) map[string]string {
+	annotations := map[string]string{
+		DeploymentNamespaceAnnotation: namespace,
+		DeploymentNameAnnotation:      name,
+		WorkloadKindAnnotation:        kind,
+		ExpiresAnnotation:             "",
+	}
+	if kind == operator.DeploymentKind {
+		annotations[WorkloadKindAnnotation] = ""
+	}
+	if !expires.IsZero() {
+		annotations[ExpiresAnnotation] = expires.UTC().Format(time.RFC3339)
+	}
+	return annotations
+}
+
+// Acquire takes the advisory lease for the given operator, or renews it when it is already held by the holder.
+// When the lease is held by somebody else and did not expire yet, an error naming the holder is returned unless force is set.
+func Acquire(client kubernetes.Interface, operatorName, holder string, duration time.Duration, force bool) error {
+	if err := operator.EnsurePluginNamespace(client); err != nil {
+		return err
+	}
+	leases := client.CoordinationV1().Leases(operator.PluginNamespace)
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		now := metav1.NewMicroTime(time.Now())
+		seconds := int32(duration.Seconds())
+
+		lease, err := leases.Get(operatorName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = leases.Create(&coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{Name: operatorName, Namespace: operator.PluginNamespace},
+				Spec: coordinationv1.LeaseSpec{
+					HolderIdentity:       &holder,
+					LeaseDurationSeconds: &seconds,
+					AcquireTime:          &now,
+					RenewTime:            &now,
+				},
+			})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := checkHolder(lease, holder, now.Time); err != nil && !force {
+			return err
+		}
+		if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != holder {
+			lease.Spec.HolderIdentity = &holder
+			lease.Spec.AcquireTime = &now
+		}
+		lease.Spec.LeaseDurationSeconds = &seconds
+		lease.Spec.RenewTime = &now
+		_, err = leases.Update(lease)
+		return err
+	})
+}
+
+// Release removes the lease for the given operator. Leases held by somebody else are only removed when force is set.
+func Release(client kubernetes.Interface, operatorName, holder string, force bool) error {
+	lease, err := client.CoordinationV1().Leases(operator.PluginNamespace).Get(operatorName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkHolder(lease, holder, time.Now()); err != nil && !force {
+		return err
+	}
+	err = client.CoordinationV1().Leases(operator.PluginNamespace).Delete(operatorName, &metav1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Expiry returns the time the lease expires at.
+func Expiry(lease *coordinationv1.Lease) time.Time {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return time.Time{}
+	}
+	return lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+}
+
+// CheckHolder returns an error when the operator lock is held by somebody else and did not expire yet.
+func CheckHolder(client kubernetes.Interface, operatorName, holder string) error {
+	lease, err := Get(client, operatorName)
+	if err != nil || lease == nil {
+		return err
+	}
+	return checkHolder(lease, holder, time.Now())
+}
+
+// CheckWorkload returns an error when the workload is locked through another operator lock held by somebody else, as
+// the same workload can be overridden through its clusteroperator name or its kind, namespace and name.
+func CheckWorkload(client kubernetes.Interface, operatorName, holder, kind, namespace, name string) error {
+	leases, err := List(client)
+	if err != nil {
+		return err
+	}
+	return checkWorkload(leases, operatorName, holder, kind, namespace, name, time.Now())
+}
+
+// checkWorkload returns an error when any other lease records the same workload and is held by somebody else.
+func checkWorkload(leases []coordinationv1.Lease, operatorName, holder, kind, namespace, name string, now time.Time) error {
+	for i := range leases {
+		lease := &leases[i]
+		if lease.Name == operatorName || lease.Annotations[DeploymentNamespaceAnnotation] != namespace || lease.Annotations[DeploymentNameAnnotation] != name {
+			continue
+		}
+		leaseKind := lease.Annotations[WorkloadKindAnnotation]
+		if len(leaseKind) == 0 {
+			leaseKind = operator.DeploymentKind
+		}
+		if leaseKind != kind {
+			continue
+		}
+		if err := checkHolder(lease, holder, now); err != nil {
+			return fmt.Errorf("%s is locked through %v", operator.WorkloadString(kind, namespace, name), err)
+		}
+	}
+	return nil
+}
+
+// checkHolder returns an error when the lease is held by somebody else and not expired at the given time.
+func checkHolder(lease *coordinationv1.Lease, holder string, now time.Time) error {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == holder {
+		return nil
+	}
+	expiry := Expiry(lease)
+	if !now.Before(expiry) {
+		return nil
+	}
+	since := "unknown time"
+	if lease.Spec.AcquireTime != nil {
+		since = lease.Spec.AcquireTime.Local().Format(time.RFC3339)
+	}
+	return fmt.Errorf("operator %q is locked by %q since %s (expires in %s), use --force to take it over",
+		lease.Name, *lease.Spec.HolderIdentity, since, expiry.Sub(now).Round(time.Second))
+}
+
+// Annotate sets the annotations on the operator lease. Annotations with empty value are removed.
+func Annotate(client kubernetes.Interface, operatorName string, annotations map[string]string) error {
+	leases := client.CoordinationV1().Leases(operator.PluginNamespace)
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		lease, err := leases.Get(operatorName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if lease.Annotations == nil {
+			lease.Annotations = map[string]string{}
+		}
+		for k, v := range annotations {
+			if len(v) == 0 {
+				delete(lease.Annotations, k)
+				continue
+			}
+			lease.Annotations[k] = v
+		}
+		_, err = leases.Update(lease)
+		return err
+	})
+}
+
+// List returns the leases of all operators currently overridden.
+func List(client kubernetes.Interface) ([]coordinationv1.Lease, error) {
+	leases, err := client.CoordinationV1().Leases(operator.PluginNamespace).List(metav1.ListOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return leases.Items, nil
+}
+
+// OverrideExpiry returns the time after which the override should be reverted, if the override has a TTL.
+func OverrideExpiry(lease *coordinationv1.Lease) (time.Time, bool) {
+	value, ok := lease.Annotations[ExpiresAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+	expiry, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expiry, true
+}
+
+// Get returns the lease of the given operator, or nil when the operator is not locked.
+func Get(client kubernetes.Interface, operatorName string) (*coordinationv1.Lease, error) {
+	lease, err := client.CoordinationV1().Leases(operator.PluginNamespace).Get(operatorName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// Restore puts back the lease of the given operator as it was before it was acquired. A nil lease means the operator was not locked.
+func Restore(client kubernetes.Interface, operatorName string, previous *coordinationv1.Lease) error {
+	if previous == nil {
+		return Release(client, operatorName, "", true)
+	}
+	leases := client.CoordinationV1().Leases(operator.PluginNamespace)
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		lease, err := leases.Get(operatorName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			restored := previous.DeepCopy()
+			restored.ResourceVersion = ""
+			_, err = leases.Create(restored)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		lease.Annotations = previous.Annotations
+		lease.Spec = previous.Spec
+		_, err = leases.Update(lease)
+		return err
+	})
+}
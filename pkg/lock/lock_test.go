@@ -0,0 +1,84 @@
+package lock
+
+import (
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_checkHolder(t *testing.T) {
+	now := time.Now()
+	newLease := func(holder string, renewed time.Time) *coordinationv1.Lease {
+		seconds := int32(3600)
+		renewTime := metav1.NewMicroTime(renewed)
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: "kube-apiserver"},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &renewTime,
+				RenewTime:            &renewTime,
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		lease       *coordinationv1.Lease
+		expectError bool
+	}{
+		"held by same user":           {lease: newLease("alice", now)},
+		"held by other user":          {lease: newLease("bob", now), expectError: true},
+		"expired lease of other user": {lease: newLease("bob", now.Add(-2*time.Hour))},
+		"no holder":                   {lease: &coordinationv1.Lease{}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkHolder(test.lease, "alice", now)
+			if test.expectError && err == nil {
+				t.Errorf("expected error")
+			}
+			if !test.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func Test_checkWorkload(t *testing.T) {
+	now := time.Now()
+	seconds := int32(3600)
+	renewTime := metav1.NewMicroTime(now)
+	newLease := func(name, holder, kind string) coordinationv1.Lease {
+		return coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{
+				DeploymentNamespaceAnnotation: "openshift-kube-apiserver-operator",
+				DeploymentNameAnnotation:      "kube-apiserver-operator",
+				WorkloadKindAnnotation:        kind,
+			}},
+			Spec: coordinationv1.LeaseSpec{HolderIdentity: &holder, LeaseDurationSeconds: &seconds, AcquireTime: &renewTime, RenewTime: &renewTime},
+		}
+	}
+
+	tests := map[string]struct {
+		leases      []coordinationv1.Lease
+		expectError bool
+	}{
+		"no other lease":             {leases: []coordinationv1.Lease{newLease("deployment.openshift-kube-apiserver-operator.kube-apiserver-operator", "bob", "")}},
+		"other target of same user":  {leases: []coordinationv1.Lease{newLease("kube-apiserver", "alice", "")}},
+		"other target of other user": {leases: []coordinationv1.Lease{newLease("kube-apiserver", "bob", "")}, expectError: true},
+		"other workload kind":        {leases: []coordinationv1.Lease{newLease("kube-apiserver", "bob", "DaemonSet")}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkWorkload(test.leases, "deployment.openshift-kube-apiserver-operator.kube-apiserver-operator", "alice",
+				"Deployment", "openshift-kube-apiserver-operator", "kube-apiserver-operator", now)
+			if (err != nil) != test.expectError {
+				t.Errorf("expected error %v, got %v", test.expectError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package manifests
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+	overridestate "github.com/mfojtik/operator-dev-plugin/pkg/state"
+)
+
+// mappingTimeout is how long to wait for the resource of a custom resource definition created by the manifests.
+const mappingTimeout = 30 * time.Second
+
+// resettableRESTMapper is a REST mapper caching the discovery, like the deferred discovery REST mapper. The cache must be
+// reset to find the resources of custom resource definitions created after the first lookup.
+type resettableRESTMapper interface {
+	meta.RESTMapper
+	Reset()
+}
+
+// Applied records an object applied from the manifests together with its state before, so it can be reverted.
+type Applied struct {
+	GVR       schema.GroupVersionResource `json:"gvr"`
+	Namespace string                      `json:"namespace,omitempty"`
+	Name      string                      `json:"name"`
+	Override  operator.ComponentOverride  `json:"override"`
+	// Previous is the object before it was applied, nil means the object was created.
+	Previous *unstructured.Unstructured `json:"previous,omitempty"`
+}
+
+func (a *Applied) String() string {
+	if len(a.Namespace) > 0 {
+		return fmt.Sprintf("%s/%s -n %s", a.GVR.Resource, a.Name, a.Namespace)
+	}
+	return fmt.Sprintf("%s/%s", a.GVR.Resource, a.Name)
+}
+
+func (a *Applied) resource(client dynamic.Interface) dynamic.ResourceInterface {
+	if len(a.Namespace) > 0 {
+		return client.Resource(a.GVR).Namespace(a.Namespace)
+	}
+	return client.Resource(a.GVR)
+}
+
+// Apply creates the object, or merges it into the existing object the way the manifest is written.
+func Apply(client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured) (*Applied, error) {
+	gvk := obj.GroupVersionKind()
+	var mapping *meta.RESTMapping
+	var mappingErr error
+	// the resource might be defined by a custom resource definition created just before
+	err := wait.PollImmediate(2*time.Second, mappingTimeout, func() (bool, error) {
+		mapping, mappingErr = mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(mappingErr) {
+			if resettable, ok := mapper.(resettableRESTMapper); ok {
+				resettable.Reset()
+			}
+		}
+		return mappingErr == nil, nil
+	})
+	if err != nil {
+		if mappingErr != nil {
+			err = mappingErr
+		}
+		return nil, fmt.Errorf("unable to find resource for %s: %v", gvk, err)
+	}
+
+	applied := &Applied{
+		GVR:      mapping.Resource,
+		Name:     obj.GetName(),
+		Override: operator.ObjectOverride(obj),
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		applied.Namespace = obj.GetNamespace()
+	}
+
+	current, err := applied.resource(client).Get(obj.GetName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if _, err := applied.resource(client).Create(obj, metav1.CreateOptions{}); err != nil {
+			return nil, fmt.Errorf("unable to create %s: %v", applied, err)
+		}
+		return applied, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	applied.Previous = current
+
+	patch, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := applied.resource(client).Patch(obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return nil, fmt.Errorf("unable to update %s: %v", applied, err)
+	}
+	return applied, nil
+}
+
+// Revert deletes the created object, or puts back the object as it was before it was applied.
+func Revert(client dynamic.Interface, applied *Applied) error {
+	if applied.Previous == nil {
+		err := applied.resource(client).Delete(applied.Name, &metav1.DeleteOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	current, err := applied.resource(client).Get(applied.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		previous := applied.Previous.DeepCopy()
+		previous.SetResourceVersion("")
+		_, err = applied.resource(client).Create(previous, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	previous := applied.Previous.DeepCopy()
+	previous.SetResourceVersion(current.GetResourceVersion())
+	_, err = applied.resource(client).Update(previous, metav1.UpdateOptions{})
+	return err
+}
+
+// RevertRules returns the RBAC rules needed to revert the applied objects, one rule for every resource.
+func RevertRules(applied []*Applied) []rbacv1.PolicyRule {
+	resources := map[schema.GroupResource]bool{}
+	for _, a := range applied {
+		resources[a.GVR.GroupResource()] = true
+	}
+	var rules []rbacv1.PolicyRule
+	for resource := range resources {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{resource.Group},
+			Resources: []string{resource.Resource},
+			Verbs:     []string{"get", "create", "update", "delete"},
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].APIGroups[0] != rules[j].APIGroups[0] {
+			return rules[i].APIGroups[0] < rules[j].APIGroups[0]
+		}
+		return rules[i].Resources[0] < rules[j].Resources[0]
+	})
+	return rules
+}
+
+// stateKey is the key of the objects applied from the manifests in the override state.
+const stateKey = "manifests"
+
+// LoadState returns the objects applied for the operator, nil means no manifests were applied.
+func LoadState(client kubernetes.Interface, operatorName string) ([]*Applied, error) {
+	var state []*Applied
+	if _, err := overridestate.Load(client, operatorName, stateKey, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// SaveState adds the applied objects to the state of the operator. Objects already recorded keep their original previous
+// state, so applying the manifests again still reverts to the state before the first apply. The status and the managed
+// fields are not saved, they are not reverted and they would make the state hit the config map size limit.
+func SaveState(client kubernetes.Interface, operatorName string, applied []*Applied) error {
+	state, err := LoadState(client, operatorName)
+	if err != nil {
+		return err
+	}
+	return overridestate.Save(client, operatorName, stateKey, mergeState(state, compact(applied)))
+}
+
+// compact returns the applied objects without the status and the managed fields of the previous objects.
+func compact(applied []*Applied) []*Applied {
+	var compacted []*Applied
+	for _, a := range applied {
+		c := *a
+		if a.Previous != nil {
+			c.Previous = a.Previous.DeepCopy()
+			unstructured.RemoveNestedField(c.Previous.Object, "status")
+			c.Previous.SetManagedFields(nil)
+		}
+		compacted = append(compacted, &c)
+	}
+	return compacted
+}
+
+// RemoveState removes the state of the operator once the manifests were reverted.
+func RemoveState(client kubernetes.Interface, operatorName string) error {
+	return overridestate.Remove(client, operatorName, stateKey)
+}
+
+func mergeState(state, applied []*Applied) []*Applied {
+	for _, a := range applied {
+		recorded := false
+		for _, s := range state {
+			if s.GVR == a.GVR && s.Namespace == a.Namespace && s.Name == a.Name {
+				recorded = true
+				break
+			}
+		}
+		if !recorded {
+			state = append(state, a)
+		}
+	}
+	return state
+}
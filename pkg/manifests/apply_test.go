@@ -0,0 +1,124 @@
+package manifests
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var crdGVK = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"}
+
+// fakeDiscovery is the kinds served by the fake cluster, the custom resource definitions add their kinds when created.
+type fakeDiscovery struct {
+	kinds map[schema.GroupVersionKind]meta.RESTScope
+}
+
+func (d *fakeDiscovery) mapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for gvk, scope := range d.kinds {
+		mapper.Add(gvk, scope)
+	}
+	return mapper
+}
+
+// cachedRESTMapper caches the discovery after the first lookup, like the deferred discovery REST mapper.
+type cachedRESTMapper struct {
+	meta.RESTMapper
+	discovery *fakeDiscovery
+}
+
+func (m *cachedRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	if m.RESTMapper == nil {
+		m.RESTMapper = m.discovery.mapper()
+	}
+	return m.RESTMapper.RESTMapping(gk, versions...)
+}
+
+func (m *cachedRESTMapper) Reset() {
+	m.RESTMapper = nil
+}
+
+type fakeDynamicClient struct {
+	dynamic.Interface
+	discovery *fakeDiscovery
+	objects   map[schema.GroupVersionResource]map[string]*unstructured.Unstructured
+}
+
+func (c *fakeDynamicClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &fakeResource{client: c, gvr: gvr}
+}
+
+type fakeResource struct {
+	dynamic.NamespaceableResourceInterface
+	client    *fakeDynamicClient
+	gvr       schema.GroupVersionResource
+	namespace string
+}
+
+func (r *fakeResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &fakeResource{client: r.client, gvr: r.gvr, namespace: namespace}
+}
+
+func (r *fakeResource) Get(name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if obj, ok := r.client.objects[r.gvr][r.namespace+"/"+name]; ok {
+		return obj, nil
+	}
+	return nil, errors.NewNotFound(r.gvr.GroupResource(), name)
+}
+
+func (r *fakeResource) Create(obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if r.client.objects[r.gvr] == nil {
+		r.client.objects[r.gvr] = map[string]*unstructured.Unstructured{}
+	}
+	r.client.objects[r.gvr][r.namespace+"/"+obj.GetName()] = obj
+	if obj.GroupVersionKind() == crdGVK {
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		version, _, _ := unstructured.NestedString(obj.Object, "spec", "version")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		r.client.discovery.kinds[schema.GroupVersionKind{Group: group, Version: version, Kind: kind}] = meta.RESTScopeNamespace
+	}
+	return obj, nil
+}
+
+func TestApplyCustomResourceDefinition(t *testing.T) {
+	discovery := &fakeDiscovery{kinds: map[schema.GroupVersionKind]meta.RESTScope{crdGVK: meta.RESTScopeRoot}}
+	client := &fakeDynamicClient{discovery: discovery, objects: map[schema.GroupVersionResource]map[string]*unstructured.Unstructured{}}
+	mapper := &cachedRESTMapper{discovery: discovery}
+
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1beta1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": "foos.example.openshift.io"},
+		"spec": map[string]interface{}{
+			"group":   "example.openshift.io",
+			"version": "v1",
+			"scope":   "Namespaced",
+			"names":   map[string]interface{}{"kind": "Foo", "plural": "foos"},
+		},
+	}}
+	foo := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.openshift.io/v1",
+		"kind":       "Foo",
+		"metadata":   map[string]interface{}{"name": "cluster", "namespace": "openshift-foo"},
+	}}
+
+	if _, err := Apply(client, mapper, crd); err != nil {
+		t.Fatalf("unexpected error applying custom resource definition: %v", err)
+	}
+	applied, err := Apply(client, mapper, foo)
+	if err != nil {
+		t.Fatalf("unexpected error applying custom resource: %v", err)
+	}
+	expected := schema.GroupVersionResource{Group: "example.openshift.io", Version: "v1", Resource: "foos"}
+	if applied.GVR != expected || applied.Namespace != "openshift-foo" || applied.Previous != nil {
+		t.Errorf("expected created %s in openshift-foo, got %#v", expected, applied)
+	}
+	if _, ok := client.objects[expected]["openshift-foo/cluster"]; !ok {
+		t.Errorf("expected custom resource to be created")
+	}
+}
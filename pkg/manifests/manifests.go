@@ -0,0 +1,249 @@
+package manifests
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// imageReferencesFile is the image stream listing the images referenced by the manifests. The release tooling replaces
+// the placeholder pull specs in the manifests with the images of the release payload.
+const imageReferencesFile = "image-references"
+
+// The annotations the cluster version operator uses to select the manifests it applies.
+const (
+	profileAnnotationPrefix = "include.release.openshift.io/"
+	featureSetAnnotation    = "release.openshift.io/feature-set"
+	deleteAnnotation        = "release.openshift.io/delete"
+)
+
+// documentSeparator splits the multi-document YAML files.
+var documentSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// Document is a single YAML document from a manifest file.
+type Document struct {
+	File string
+	Data []byte
+}
+
+// Manifest is an object from the manifest files.
+type Manifest struct {
+	File   string
+	Object *unstructured.Unstructured
+}
+
+// ReadDocuments reads the documents from the numbered manifest files (0000_*.yaml) in the order the cluster version
+// operator applies them, which is the order of file names. Only the documents the cluster version operator applies in a
+// cluster with the given profile and feature set are returned.
+func ReadDocuments(dir, profile, featureSet string) ([]Document, error) {
+	var files []string
+	for _, pattern := range []string{"0000_*.yaml", "0000_*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no manifests (0000_*.yaml) found in %s", dir)
+	}
+	sort.Strings(files)
+
+	var documents []Document
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, document := range documentSeparator.Split(string(data), -1) {
+			if len(strings.TrimSpace(document)) == 0 {
+				continue
+			}
+			d := Document{File: filepath.Base(file), Data: []byte(document)}
+			obj, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			if !included(obj, profile, featureSet) {
+				continue
+			}
+			documents = append(documents, d)
+		}
+	}
+	return documents, nil
+}
+
+// included returns true when the cluster version operator applies the object in a cluster with the given profile and
+// feature set. The objects marked for deletion are removed by the cluster version operator, they are not applied.
+func included(obj *unstructured.Unstructured, profile, featureSet string) bool {
+	annotations := obj.GetAnnotations()
+	if annotations[deleteAnnotation] == "true" {
+		return false
+	}
+	if annotations[profileAnnotationPrefix+profile] != "true" {
+		return false
+	}
+	featureSets, ok := annotations[featureSetAnnotation]
+	if !ok {
+		return true
+	}
+	if len(featureSet) == 0 {
+		featureSet = "Default"
+	}
+	for _, s := range strings.Split(featureSets, ",") {
+		if strings.TrimSpace(s) == featureSet {
+			return true
+		}
+	}
+	return false
+}
+
+func (d Document) decode() (*unstructured.Unstructured, error) {
+	jsonData, err := yaml.YAMLToJSON(d.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", d.File, err)
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(jsonData); err != nil {
+		return nil, fmt.Errorf("%s: %v", d.File, err)
+	}
+	return obj, nil
+}
+
+// ReadImageReferences returns the placeholder pull specs from the image-references file, keyed by the image tag name.
+// Missing file means the manifests do not reference any images.
+func ReadImageReferences(dir string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, imageReferencesFile))
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	imageStream := &unstructured.Unstructured{}
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", imageReferencesFile, err)
+	}
+	if err := imageStream.UnmarshalJSON(jsonData); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", imageReferencesFile, err)
+	}
+	tags, _, err := unstructured.NestedSlice(imageStream.Object, "spec", "tags")
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", imageReferencesFile, err)
+	}
+	references := map[string]string{}
+	for _, x := range tags {
+		tag, ok := x.(map[string]interface{})
+		if !ok {
+			continue // ignore
+		}
+		name, _, _ := unstructured.NestedString(tag, "name")
+		from, _, _ := unstructured.NestedString(tag, "from", "name")
+		if len(name) > 0 && len(from) > 0 {
+			references[name] = from
+		}
+	}
+	return references, nil
+}
+
+// Decode replaces the placeholder pull specs with the images and decodes the documents. It fails when any placeholder
+// from the image references is left in the manifests.
+func Decode(documents []Document, references map[string]string, replacements map[string]string) ([]Manifest, error) {
+	var manifests []Manifest
+	for _, document := range documents {
+		data := document.Data
+		for placeholder, image := range replacements {
+			data = bytes.Replace(data, []byte(placeholder), []byte(image), -1)
+		}
+		for tag, placeholder := range references {
+			if bytes.Contains(data, []byte(placeholder)) {
+				return nil, fmt.Errorf("%s: no image given for %q (%s), use --image-reference=%s=<image>", document.File, tag, placeholder, tag)
+			}
+		}
+		obj, err := Document{File: document.File, Data: data}.decode()
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, Manifest{File: document.File, Object: obj})
+	}
+	return manifests, nil
+}
+
+// FindDeployment returns the deployment with the given namespace and name from the documents, or nil when there is none.
+// The placeholders are kept, so the images referenced by the deployment can be matched with the running deployment.
+func FindDeployment(documents []Document, namespace, name string) (*appsv1.Deployment, error) {
+	for _, document := range documents {
+		obj, err := document.decode()
+		if err != nil {
+			return nil, err
+		}
+		if obj.GetKind() != "Deployment" || obj.GetNamespace() != namespace || obj.GetName() != name {
+			continue
+		}
+		deployment := &appsv1.Deployment{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deployment); err != nil {
+			return nil, fmt.Errorf("%s: %v", document.File, err)
+		}
+		return deployment, nil
+	}
+	return nil, nil
+}
+
+// ResolveImages maps the placeholder pull specs to images. Explicit images are keyed by the image tag name. The operator
+// image replaces the image of the first container of the manifest deployment. The placeholders of the other images used
+// by the manifest deployment (in container images or environment variables) are replaced with the images the running
+// deployment uses, which are the images of the installed release.
+func ResolveImages(references, explicit map[string]string, operatorImage string, manifestDeployment, runningDeployment *appsv1.Deployment) (map[string]string, error) {
+	replacements := map[string]string{}
+	isPlaceholder := map[string]bool{}
+	for _, placeholder := range references {
+		isPlaceholder[placeholder] = true
+	}
+
+	if manifestDeployment != nil && runningDeployment != nil {
+		running := map[string]string{}
+		for _, container := range runningDeployment.Spec.Template.Spec.Containers {
+			running["container/"+container.Name] = container.Image
+			for _, env := range container.Env {
+				running["env/"+container.Name+"/"+env.Name] = env.Value
+			}
+		}
+		for _, container := range manifestDeployment.Spec.Template.Spec.Containers {
+			if isPlaceholder[container.Image] && len(running["container/"+container.Name]) > 0 {
+				replacements[container.Image] = running["container/"+container.Name]
+			}
+			for _, env := range container.Env {
+				if isPlaceholder[env.Value] && len(running["env/"+container.Name+"/"+env.Name]) > 0 {
+					replacements[env.Value] = running["env/"+container.Name+"/"+env.Name]
+				}
+			}
+		}
+	}
+
+	if len(operatorImage) > 0 {
+		if manifestDeployment == nil || len(manifestDeployment.Spec.Template.Spec.Containers) == 0 {
+			return nil, fmt.Errorf("the manifests do not contain the operator deployment, use --image-reference to set the operator image")
+		}
+		replacements[manifestDeployment.Spec.Template.Spec.Containers[0].Image] = operatorImage
+	}
+
+	for tag, image := range explicit {
+		placeholder, ok := references[tag]
+		if !ok {
+			return nil, fmt.Errorf("image %q is not listed in %s", tag, imageReferencesFile)
+		}
+		replacements[placeholder] = image
+	}
+	return replacements, nil
+}
@@ -0,0 +1,264 @@
+package manifests
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	operatorPlaceholder = "quay.io/openshift/origin-cluster-foo-operator:v4.3"
+	operandPlaceholder  = "quay.io/openshift/origin-foo:v4.3"
+)
+
+var testFiles = map[string]string{
+	"0000_50_foo-operator_07_deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  namespace: openshift-foo-operator
+  name: foo-operator
+  annotations:
+    include.release.openshift.io/self-managed-high-availability: "true"
+    release.openshift.io/feature-set: Default
+spec:
+  template:
+    spec:
+      containers:
+      - name: operator
+        image: ` + operatorPlaceholder + `
+        env:
+        - name: IMAGE
+          value: ` + operandPlaceholder + `
+`,
+	"0000_50_foo-operator_00_namespace.yaml": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: openshift-foo-operator
+  annotations:
+    include.release.openshift.io/self-managed-high-availability: "true"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  namespace: openshift-foo-operator
+  name: config
+  annotations:
+    include.release.openshift.io/self-managed-high-availability: "true"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  namespace: openshift-foo-operator
+  name: removed
+  annotations:
+    include.release.openshift.io/self-managed-high-availability: "true"
+    release.openshift.io/delete: "true"
+`,
+	"0000_50_foo-operator_07_deployment-techpreview.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  namespace: openshift-foo-operator
+  name: foo-operator
+  annotations:
+    include.release.openshift.io/self-managed-high-availability: "true"
+    release.openshift.io/feature-set: TechPreviewNoUpgrade,CustomNoUpgrade
+spec:
+  template:
+    spec:
+      containers:
+      - name: operator-techpreview
+        image: ` + operatorPlaceholder + `
+`,
+	"0000_50_foo-operator_07_deployment-hypershift.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  namespace: openshift-foo-operator
+  name: foo-operator
+  annotations:
+    include.release.openshift.io/ibm-cloud-managed: "true"
+spec:
+  template:
+    spec:
+      containers:
+      - name: operator-hypershift
+        image: ` + operatorPlaceholder + `
+`,
+	"image-references": `kind: ImageStream
+apiVersion: image.openshift.io/v1
+spec:
+  tags:
+  - name: cluster-foo-operator
+    from:
+      kind: DockerImage
+      name: ` + operatorPlaceholder + `
+  - name: foo
+    from:
+      kind: DockerImage
+      name: ` + operandPlaceholder + `
+`,
+	"README.md": "not a manifest",
+}
+
+func writeTestManifests(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "manifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range testFiles {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadAndDecode(t *testing.T) {
+	dir := writeTestManifests(t)
+	defer os.RemoveAll(dir)
+
+	documents, err := ReadDocuments(dir, "self-managed-high-availability", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	references, err := ReadImageReferences(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(references) != 2 {
+		t.Fatalf("expected 2 image references, got %v", references)
+	}
+
+	manifestDeployment, err := FindDeployment(documents, "openshift-foo-operator", "foo-operator")
+	if err != nil || manifestDeployment == nil {
+		t.Fatalf("expected deployment to be found, got %v", err)
+	}
+	if name := manifestDeployment.Spec.Template.Spec.Containers[0].Name; name != "operator" {
+		t.Errorf("expected the deployment of the default feature set and cluster profile, got container %q", name)
+	}
+	running := &appsv1.Deployment{}
+	running.Spec.Template.Spec.Containers = []corev1.Container{
+		{Name: "operator", Image: "release/foo-operator@sha256:1", Env: []corev1.EnvVar{{Name: "IMAGE", Value: "release/foo@sha256:2"}}},
+	}
+
+	if _, err := Decode(documents, references, nil); err == nil {
+		t.Errorf("expected error for unresolved image placeholders")
+	}
+
+	replacements, err := ResolveImages(references, nil, "docker.io/me/foo-operator:dev", manifestDeployment, running)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := Decode(documents, references, replacements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, m := range manifests {
+		kinds = append(kinds, m.Object.GetKind())
+	}
+	if len(kinds) != 3 || kinds[0] != "Namespace" || kinds[1] != "ConfigMap" || kinds[2] != "Deployment" {
+		t.Errorf("expected manifests in run-level order, got %v", kinds)
+	}
+	containers, _, _ := unstructured.NestedSlice(manifests[2].Object.Object, "spec", "template", "spec", "containers")
+	container := containers[0].(map[string]interface{})
+	if container["image"] != "docker.io/me/foo-operator:dev" {
+		t.Errorf("expected operator image to be replaced, got %v", container["image"])
+	}
+	if env := container["env"].([]interface{})[0].(map[string]interface{}); env["value"] != "release/foo@sha256:2" {
+		t.Errorf("expected operand image from the running deployment, got %v", env["value"])
+	}
+
+	if _, err := ResolveImages(references, map[string]string{"unknown": "x"}, "", manifestDeployment, running); err == nil {
+		t.Errorf("expected error for unknown image reference")
+	}
+}
+
+func TestReadDocumentsFeatureSet(t *testing.T) {
+	dir := writeTestManifests(t)
+	defer os.RemoveAll(dir)
+
+	documents, err := ReadDocuments(dir, "self-managed-high-availability", "TechPreviewNoUpgrade")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, document := range documents {
+		files = append(files, document.File)
+	}
+	// the namespace and the config map, the removed config map is skipped
+	if len(files) != 3 || files[2] != "0000_50_foo-operator_07_deployment-techpreview.yaml" {
+		t.Errorf("expected only the tech preview deployment, got %v", files)
+	}
+
+	documents, err = ReadDocuments(dir, "ibm-cloud-managed", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(documents) != 1 || documents[0].File != "0000_50_foo-operator_07_deployment-hypershift.yaml" {
+		t.Errorf("expected only the manifests of the ibm-cloud-managed profile, got %v", documents)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	previous := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name":          "openshift-foo",
+			"managedFields": []interface{}{map[string]interface{}{"manager": "cluster-version-operator"}},
+		},
+		"status": map[string]interface{}{"phase": "Active"},
+	}}
+	applied := []*Applied{{GVR: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, Name: "openshift-foo", Previous: previous}, {Name: "created"}}
+
+	compacted := compact(applied)
+	if _, ok := compacted[0].Previous.Object["status"]; ok {
+		t.Errorf("expected the status to be removed, got %v", compacted[0].Previous.Object)
+	}
+	if _, ok, _ := unstructured.NestedFieldNoCopy(compacted[0].Previous.Object, "metadata", "managedFields"); ok {
+		t.Errorf("expected the managed fields to be removed, got %v", compacted[0].Previous.Object)
+	}
+	if compacted[0].Previous.GetName() != "openshift-foo" || compacted[1].Previous != nil {
+		t.Errorf("unexpected compacted state: %#v", compacted)
+	}
+	if _, ok := previous.Object["status"]; !ok {
+		t.Errorf("expected the applied objects to be kept for the rollback")
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	previous := &unstructured.Unstructured{}
+	previous.SetAPIVersion("v1")
+	previous.SetKind("ConfigMap")
+	previous.SetName("config")
+	first := &Applied{GVR: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, Namespace: "ns", Name: "config", Previous: previous}
+
+	changed := previous.DeepCopy()
+	changed.SetLabels(map[string]string{"changed": "true"})
+	second := &Applied{GVR: first.GVR, Namespace: "ns", Name: "config", Previous: changed}
+	created := &Applied{GVR: first.GVR, Namespace: "ns", Name: "new"}
+
+	state := mergeState(mergeState(nil, []*Applied{first}), []*Applied{second, created})
+	if len(state) != 2 || len(state[0].Previous.GetLabels()) > 0 {
+		t.Fatalf("expected original previous state to be kept, got %#v", state)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded []*Applied
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded[0].Previous.GetName() != "config" || loaded[1].Previous != nil {
+		t.Errorf("unexpected state after round trip: %s", data)
+	}
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+)
+
+// valueMetrics are the gauges and counters compared as they are.
+var valueMetrics = map[string]bool{
+	"workqueue_depth":            true,
+	"rest_client_requests_total": true,
+	"go_goroutines":              true,
+}
+
+// histogramMetrics are the histograms compared by their average and number of observations.
+var histogramMetrics = map[string]bool{
+	"workqueue_work_duration_seconds":  true,
+	"workqueue_queue_duration_seconds": true,
+}
+
+// Summarize returns the values of the series relevant for operator performance, keyed by series.
+// Histograms are reduced to <name>_avg and <name>_count series.
+func Summarize(samples []Sample) map[string]float64 {
+	summary := map[string]float64{}
+	sums := map[string]float64{}
+	for _, sample := range samples {
+		if valueMetrics[sample.Name] {
+			summary[sample.Key()] = sample.Value
+			continue
+		}
+		if name := strings.TrimSuffix(sample.Name, "_sum"); name != sample.Name && histogramMetrics[name] {
+			sums[seriesKey(name+"_avg", sample.Labels)] = sample.Value
+			continue
+		}
+		if name := strings.TrimSuffix(sample.Name, "_count"); name != sample.Name && histogramMetrics[name] {
+			summary[sample.Key()] = sample.Value
+		}
+	}
+	for key, sum := range sums {
+		count := summary[strings.Replace(key, "_avg", "_count", 1)]
+		if count > 0 {
+			summary[key] = sum / count
+		}
+	}
+	return summary
+}
+
+// Change is the value of a series before and after.
+type Change struct {
+	Key       string
+	Before    float64
+	After     float64
+	HasBefore bool
+	HasAfter  bool
+}
+
+// Compare returns the changes between two summaries, sorted by series.
+func Compare(before, after map[string]float64) []Change {
+	changes := map[string]*Change{}
+	for key, value := range before {
+		changes[key] = &Change{Key: key, Before: value, HasBefore: true}
+	}
+	for key, value := range after {
+		change, ok := changes[key]
+		if !ok {
+			change = &Change{Key: key}
+			changes[key] = change
+		}
+		change.After, change.HasAfter = value, true
+	}
+	result := make([]Change, 0, len(changes))
+	for _, change := range changes {
+		result = append(result, *change)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+const exposition = `# HELP workqueue_depth Current depth of workqueue
+# TYPE workqueue_depth gauge
+workqueue_depth{name="ConfigObserver"} 2
+workqueue_depth{name="with \"quotes\", and comma"} 0
+# TYPE workqueue_work_duration_seconds histogram
+workqueue_work_duration_seconds_bucket{name="ConfigObserver",le="0.1"} 3
+workqueue_work_duration_seconds_sum{name="ConfigObserver"} 1.5
+workqueue_work_duration_seconds_count{name="ConfigObserver"} 3
+rest_client_requests_total{code="200",host="172.30.0.1:443",method="GET"} 42 1571234567000
+go_goroutines 120
+process_cpu_seconds_total 12.5
+`
+
+func TestParse(t *testing.T) {
+	samples, err := Parse(strings.NewReader(exposition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 8 {
+		t.Fatalf("expected 8 samples, got %d", len(samples))
+	}
+	if name := samples[1].Labels["name"]; name != `with "quotes", and comma` {
+		t.Errorf("unexpected label value %q", name)
+	}
+	if key := samples[5].Key(); key != `rest_client_requests_total{code="200",host="172.30.0.1:443",method="GET"}` {
+		t.Errorf("unexpected key %q", key)
+	}
+
+	if _, err := Parse(strings.NewReader(`broken{name="x} 1`)); err == nil {
+		t.Errorf("expected error for unterminated label")
+	}
+}
+
+func TestSummarizeCompare(t *testing.T) {
+	samples, err := Parse(strings.NewReader(exposition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := Summarize(samples)
+	if avg := before[`workqueue_work_duration_seconds_avg{name="ConfigObserver"}`]; avg != 0.5 {
+		t.Errorf("expected average 0.5, got %v", avg)
+	}
+	if _, ok := before["process_cpu_seconds_total"]; ok {
+		t.Errorf("expected process_cpu_seconds_total to be ignored")
+	}
+
+	after := map[string]float64{"go_goroutines": 150, "workqueue_depth{name=\"New\"}": 1}
+	changes := Compare(before, after)
+	for _, change := range changes {
+		switch change.Key {
+		case "go_goroutines":
+			if change.Before != 120 || change.After != 150 {
+				t.Errorf("unexpected goroutines change %#v", change)
+			}
+		case "workqueue_depth{name=\"New\"}":
+			if change.HasBefore || !change.HasAfter {
+				t.Errorf("expected new series, got %#v", change)
+			}
+		}
+	}
+}
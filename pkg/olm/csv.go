@@ -0,0 +1,152 @@
+package olm
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	csvKind = "ClusterServiceVersion"
+
+	// the labels OLM sets on the objects it creates for a ClusterServiceVersion
+	ownerLabel          = "olm.owner"
+	ownerNamespaceLabel = "olm.owner.namespace"
+	ownerKindLabel      = "olm.owner.kind"
+)
+
+var ClusterServiceVersionGVR = schema.GroupVersionResource{Group: "operators.coreos.com", Version: "v1alpha1", Resource: "clusterserviceversions"}
+
+// ownerCSV returns the namespace and name of the ClusterServiceVersion which installed the deployment.
+func ownerCSV(deployment *appsv1.Deployment) (string, string, bool) {
+	for _, ref := range deployment.OwnerReferences {
+		if ref.Kind == csvKind && ref.APIVersion == ClusterServiceVersionGVR.GroupVersion().String() {
+			return deployment.Namespace, ref.Name, true
+		}
+	}
+	labels := deployment.Labels
+	if len(labels[ownerLabel]) > 0 && labels[ownerKindLabel] == csvKind {
+		namespace := labels[ownerNamespaceLabel]
+		if len(namespace) == 0 {
+			namespace = deployment.Namespace
+		}
+		return namespace, labels[ownerLabel], true
+	}
+	return "", "", false
+}
+
+// OwningCSV returns the ClusterServiceVersion which installed the deployment, nil means the deployment is not managed by OLM.
+func OwningCSV(client dynamic.Interface, deployment *appsv1.Deployment) (*unstructured.Unstructured, error) {
+	namespace, name, ok := ownerCSV(deployment)
+	if !ok {
+		return nil, nil
+	}
+	csv, err := client.Resource(ClusterServiceVersionGVR).Namespace(namespace).Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, fmt.Errorf("deployment %s/%s is owned by clusterserviceversion %s/%s which does not exist", deployment.Namespace, deployment.Name, namespace, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get clusterserviceversion %s/%s: %v", namespace, name, err)
+	}
+	return csv, nil
+}
+
+// UpdateDeployment changes the pod spec of the named deployment in the ClusterServiceVersion install strategy. Images
+// replaced in the pod spec (container images and environment variables) are replaced in spec.relatedImages as well, so
+// the CSV stays consistent for disconnected mirroring.
+func UpdateDeployment(csv *unstructured.Unstructured, name string, mutate func(spec *corev1.PodSpec) error) error {
+	deployments, _, err := unstructured.NestedSlice(csv.Object, "spec", "install", "spec", "deployments")
+	if err != nil {
+		return err
+	}
+	index := -1
+	for i := range deployments {
+		if deploymentName, _, _ := unstructured.NestedString(deployments[i].(map[string]interface{}), "name"); deploymentName == name {
+			index = i
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("clusterserviceversion %s has no deployment %q in the install strategy", csv.GetName(), name)
+	}
+
+	entry := deployments[index].(map[string]interface{})
+	rawSpec, _, err := unstructured.NestedMap(entry, "spec")
+	if err != nil {
+		return err
+	}
+	spec := &appsv1.DeploymentSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSpec, spec); err != nil {
+		return fmt.Errorf("invalid deployment %q in clusterserviceversion %s: %v", name, csv.GetName(), err)
+	}
+	before := spec.Template.Spec.DeepCopy()
+	if err := mutate(&spec.Template.Spec); err != nil {
+		return err
+	}
+	updated, err := runtime.DefaultUnstructuredConverter.ToUnstructured(spec)
+	if err != nil {
+		return err
+	}
+	// the converter sets creationTimestamp to null, which the CSV validation does not accept
+	unstructured.RemoveNestedField(updated, "template", "metadata", "creationTimestamp")
+	entry["spec"] = updated
+	deployments[index] = entry
+	if err := unstructured.SetNestedSlice(csv.Object, deployments, "spec", "install", "spec", "deployments"); err != nil {
+		return err
+	}
+
+	relatedImages, found, err := unstructured.NestedSlice(csv.Object, "spec", "relatedImages")
+	if err != nil || !found {
+		return err
+	}
+	relatedImages = replaceRelatedImages(relatedImages, imageChanges(before, &spec.Template.Spec))
+	return unstructured.SetNestedSlice(csv.Object, relatedImages, "spec", "relatedImages")
+}
+
+// imageChanges maps the images used in the pod spec before to the images replacing them.
+func imageChanges(before, after *corev1.PodSpec) map[string]string {
+	changes := map[string]string{}
+	add := func(old, new string) {
+		if len(old) > 0 && len(new) > 0 && old != new {
+			changes[old] = new
+		}
+	}
+	compare := func(before, after []corev1.Container) {
+		for i := range before {
+			if i >= len(after) {
+				return
+			}
+			add(before[i].Image, after[i].Image)
+			for _, oldEnv := range before[i].Env {
+				for _, newEnv := range after[i].Env {
+					if oldEnv.Name == newEnv.Name {
+						add(oldEnv.Value, newEnv.Value)
+					}
+				}
+			}
+		}
+	}
+	compare(before.InitContainers, after.InitContainers)
+	compare(before.Containers, after.Containers)
+	return changes
+}
+
+func replaceRelatedImages(relatedImages []interface{}, changes map[string]string) []interface{} {
+	for i := range relatedImages {
+		related, ok := relatedImages[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		image, _, _ := unstructured.NestedString(related, "image")
+		if replacement, ok := changes[image]; ok {
+			related["image"] = replacement
+		}
+	}
+	return relatedImages
+}
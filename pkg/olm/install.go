@@ -0,0 +1,212 @@
+package olm
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/state"
+)
+
+const (
+	// DefaultRegistryImage serves the bundle added into a new index database, the same way 'operator-sdk run bundle'
+	// does. The tag is pinned, as newer opm releases change the registry commands.
+	DefaultRegistryImage = "quay.io/operator-framework/opm:v1.23.0"
+	registryPort         = 50051
+
+	// createdByLabel marks the objects created by olm-install.
+	createdByLabel = "operator-dev.openshift.io/olm-install"
+)
+
+var (
+	CatalogSourceGVR = schema.GroupVersionResource{Group: "operators.coreos.com", Version: "v1alpha1", Resource: "catalogsources"}
+	OperatorGroupGVR = schema.GroupVersionResource{Group: "operators.coreos.com", Version: "v1", Resource: "operatorgroups"}
+	SubscriptionGVR  = schema.GroupVersionResource{Group: "operators.coreos.com", Version: "v1alpha1", Resource: "subscriptions"}
+	InstallPlanGVR   = schema.GroupVersionResource{Group: "operators.coreos.com", Version: "v1alpha1", Resource: "installplans"}
+
+	NamespaceGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	PodGVR       = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	ServiceGVR   = schema.GroupVersionResource{Version: "v1", Resource: "services"}
+)
+
+// CatalogName returns the name of the catalog source and the bundle registry created for the package.
+func CatalogName(packageName string) string {
+	return "operator-dev-" + packageName
+}
+
+func labels(packageName string) map[string]interface{} {
+	return map[string]interface{}{createdByLabel: packageName}
+}
+
+// CatalogSource returns the catalog source serving the index image. When the index image is empty, the catalog source
+// uses the bundle registry service instead.
+func CatalogSource(namespace, packageName, indexImage string) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"sourceType":  "grpc",
+		"displayName": "operator-dev " + packageName,
+		"publisher":   "operator-dev",
+	}
+	if len(indexImage) > 0 {
+		spec["image"] = indexImage
+	} else {
+		spec["address"] = fmt.Sprintf("%s.%s.svc:%d", CatalogName(packageName), namespace, registryPort)
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": CatalogSourceGVR.GroupVersion().String(),
+		"kind":       "CatalogSource",
+		"metadata": map[string]interface{}{
+			"namespace": namespace,
+			"name":      CatalogName(packageName),
+			"labels":    labels(packageName),
+		},
+		"spec": spec,
+	}}
+}
+
+// OperatorGroup returns the operator group for the namespace, watching only the namespace or all namespaces.
+func OperatorGroup(namespace, packageName string, allNamespaces bool) *unstructured.Unstructured {
+	spec := map[string]interface{}{}
+	if !allNamespaces {
+		spec["targetNamespaces"] = []interface{}{namespace}
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": OperatorGroupGVR.GroupVersion().String(),
+		"kind":       "OperatorGroup",
+		"metadata": map[string]interface{}{
+			"namespace": namespace,
+			"name":      "operator-dev",
+			"labels":    labels(packageName),
+		},
+		"spec": spec,
+	}}
+}
+
+// Subscription returns the subscription for the package from the operator-dev catalog source. The install plans are
+// approved manually, so a newer bundle pushed into the catalog is not installed while the operator is tested.
+func Subscription(namespace, packageName, channel string) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"name":                packageName,
+		"source":              CatalogName(packageName),
+		"sourceNamespace":     namespace,
+		"installPlanApproval": "Manual",
+	}
+	if len(channel) > 0 {
+		spec["channel"] = channel
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": SubscriptionGVR.GroupVersion().String(),
+		"kind":       "Subscription",
+		"metadata": map[string]interface{}{
+			"namespace": namespace,
+			"name":      packageName,
+			"labels":    labels(packageName),
+		},
+		"spec": spec,
+	}}
+}
+
+// BundleRegistry returns the pod adding the bundle image into an empty index database and serving it with the opm
+// registry image, and the service the catalog source connects to. The pod is unstructured, as the seccomp profile field
+// is not in the vendored API.
+func BundleRegistry(namespace, packageName, bundleImage, registryImage string) (*unstructured.Unstructured, *corev1.Service, error) {
+	name := CatalogName(packageName)
+	podLabels := map[string]string{createdByLabel: packageName}
+	nonRoot, privilegeEscalation := true, false
+	pod := &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: podLabels},
+		Spec: corev1.PodSpec{
+			// the registry runs in namespaces enforcing the restricted pod security standard
+			SecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: &nonRoot},
+			Volumes:         []corev1.Volume{{Name: "database", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+			Containers: []corev1.Container{
+				{
+					Name:  "registry",
+					Image: registryImage,
+					Command: []string{"/bin/sh", "-c", fmt.Sprintf(
+						"opm registry add -d /database/index.db --mode=semver -b %s && opm registry serve -d /database/index.db -p %d",
+						bundleImage, registryPort)},
+					SecurityContext: &corev1.SecurityContext{
+						AllowPrivilegeEscalation: &privilegeEscalation,
+						Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+					},
+					VolumeMounts: []corev1.VolumeMount{{Name: "database", MountPath: "/database"}},
+					Ports:        []corev1.ContainerPort{{Name: "grpc", ContainerPort: registryPort}},
+					ReadinessProbe: &corev1.Probe{
+						Handler: corev1.Handler{
+							TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(registryPort)},
+						},
+						PeriodSeconds: 5,
+					},
+				},
+			},
+		},
+	}
+	podObject, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	registryPod := &unstructured.Unstructured{Object: podObject}
+	if err := unstructured.SetNestedField(registryPod.Object, "RuntimeDefault", "spec", "securityContext", "seccompProfile", "type"); err != nil {
+		return nil, nil, err
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: podLabels},
+		Spec: corev1.ServiceSpec{
+			Selector: podLabels,
+			Ports:    []corev1.ServicePort{{Name: "grpc", Port: registryPort, TargetPort: intstr.FromInt(registryPort)}},
+		},
+	}
+	return registryPod, service, nil
+}
+
+// Object identifies an object created by olm-install.
+type Object struct {
+	GVR       schema.GroupVersionResource `json:"gvr"`
+	Namespace string                      `json:"namespace,omitempty"`
+	Name      string                      `json:"name"`
+}
+
+func (o Object) String() string {
+	if len(o.Namespace) > 0 {
+		return fmt.Sprintf("%s/%s -n %s", o.GVR.Resource, o.Name, o.Namespace)
+	}
+	return fmt.Sprintf("%s/%s", o.GVR.Resource, o.Name)
+}
+
+// InstallState records the objects created by olm-install in the order they were created.
+type InstallState struct {
+	Package   string   `json:"package"`
+	Namespace string   `json:"namespace"`
+	Created   []Object `json:"created"`
+}
+
+// installStateKey is the key of the objects created by olm-install in the state saved for the package.
+const installStateKey = "olm-install"
+
+// LoadInstallState returns the objects created for the package, nil means the package was not installed by olm-install.
+// The state is kept in the cluster, so the package can be uninstalled by other users.
+func LoadInstallState(client kubernetes.Interface, packageName string) (*InstallState, error) {
+	s := &InstallState{}
+	saved, err := state.Load(client, packageName, installStateKey, s)
+	if err != nil || !saved {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save writes the state, it is saved after every created object, so a failed install can still be uninstalled.
+func (s *InstallState) Save(client kubernetes.Interface) error {
+	return state.Save(client, s.Package, installStateKey, s)
+}
+
+// Remove removes the state once everything was uninstalled.
+func (s *InstallState) Remove(client kubernetes.Interface) error {
+	return state.Remove(client, s.Package, installStateKey)
+}
@@ -0,0 +1,175 @@
+package olm
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_ownerCSV(t *testing.T) {
+	tests := []struct {
+		name              string
+		meta              metav1.ObjectMeta
+		expectedNamespace string
+		expectedName      string
+		expectedOK        bool
+	}{
+		{
+			name: "owner reference",
+			meta: metav1.ObjectMeta{Namespace: "openshift-logging", OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "operators.coreos.com/v1alpha1", Kind: "ClusterServiceVersion", Name: "cluster-logging.v5.0.0"},
+			}},
+			expectedNamespace: "openshift-logging",
+			expectedName:      "cluster-logging.v5.0.0",
+			expectedOK:        true,
+		},
+		{
+			name: "olm labels",
+			meta: metav1.ObjectMeta{Namespace: "openshift-local-storage", Labels: map[string]string{
+				"olm.owner":           "local-storage-operator.4.6.0",
+				"olm.owner.namespace": "openshift-local-storage",
+				"olm.owner.kind":      "ClusterServiceVersion",
+			}},
+			expectedNamespace: "openshift-local-storage",
+			expectedName:      "local-storage-operator.4.6.0",
+			expectedOK:        true,
+		},
+		{
+			name: "other owner",
+			meta: metav1.ObjectMeta{Namespace: "openshift-foo-operator", OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "foo"},
+			}},
+		},
+		{
+			name: "cvo managed",
+			meta: metav1.ObjectMeta{Namespace: "openshift-foo-operator"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			namespace, name, ok := ownerCSV(&appsv1.Deployment{ObjectMeta: test.meta})
+			if namespace != test.expectedNamespace || name != test.expectedName || ok != test.expectedOK {
+				t.Errorf("expected %s/%s %v, got %s/%s %v", test.expectedNamespace, test.expectedName, test.expectedOK, namespace, name, ok)
+			}
+		})
+	}
+}
+
+func TestUpdateDeployment(t *testing.T) {
+	csv := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"namespace": "openshift-foo", "name": "foo.v1"},
+		"spec": map[string]interface{}{
+			"install": map[string]interface{}{
+				"strategy": "deployment",
+				"spec": map[string]interface{}{
+					"deployments": []interface{}{
+						map[string]interface{}{
+							"name": "foo-operator",
+							"spec": map[string]interface{}{
+								"template": map[string]interface{}{
+									"spec": map[string]interface{}{
+										"containers": []interface{}{
+											map[string]interface{}{
+												"name":  "operator",
+												"image": "quay.io/foo/operator:v1",
+												"env": []interface{}{
+													map[string]interface{}{"name": "IMAGE", "value": "quay.io/foo/operand:v1"},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"relatedImages": []interface{}{
+				map[string]interface{}{"name": "operator", "image": "quay.io/foo/operator:v1"},
+				map[string]interface{}{"name": "operand", "image": "quay.io/foo/operand:v1"},
+				map[string]interface{}{"name": "other", "image": "quay.io/foo/other:v1"},
+			},
+		},
+	}}
+	original := csv.DeepCopy()
+	state := NewState(original)
+
+	err := UpdateDeployment(csv, "foo-operator", func(spec *corev1.PodSpec) error {
+		spec.Containers[0].Image = "quay.io/me/operator:debug"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployments, _, _ := unstructured.NestedSlice(csv.Object, "spec", "install", "spec", "deployments")
+	podContainers, _, _ := unstructured.NestedSlice(deployments[0].(map[string]interface{}), "spec", "template", "spec", "containers")
+	if got := podContainers[0].(map[string]interface{})["image"]; got != "quay.io/me/operator:debug" {
+		t.Errorf("expected the operator image to be replaced, got %v", got)
+	}
+	relatedImages, _, _ := unstructured.NestedSlice(csv.Object, "spec", "relatedImages")
+	var images []string
+	for _, related := range relatedImages {
+		images = append(images, related.(map[string]interface{})["image"].(string))
+	}
+	expected := []string{"quay.io/me/operator:debug", "quay.io/foo/operand:v1", "quay.io/foo/other:v1"}
+	if !reflect.DeepEqual(images, expected) {
+		t.Errorf("expected related images %v, got %v", expected, images)
+	}
+	if !reflect.DeepEqual(state, NewState(original)) {
+		t.Errorf("expected the state to keep the original install strategy and related images")
+	}
+
+	if err := UpdateDeployment(csv, "missing", func(spec *corev1.PodSpec) error { return nil }); err == nil {
+		t.Errorf("expected error for deployment missing in the install strategy")
+	}
+}
+
+func TestCatalogSource(t *testing.T) {
+	tests := []struct {
+		name            string
+		index           string
+		expectedImage   string
+		expectedAddress string
+	}{
+		{name: "index", index: "quay.io/me/index:debug", expectedImage: "quay.io/me/index:debug"},
+		{name: "bundle registry", expectedAddress: "operator-dev-foo.openshift-foo.svc:50051"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			catalog := CatalogSource("openshift-foo", "foo", test.index)
+			image, _, _ := unstructured.NestedString(catalog.Object, "spec", "image")
+			address, _, _ := unstructured.NestedString(catalog.Object, "spec", "address")
+			if image != test.expectedImage || address != test.expectedAddress {
+				t.Errorf("expected image %q and address %q, got %q and %q", test.expectedImage, test.expectedAddress, image, address)
+			}
+			if source, _, _ := unstructured.NestedString(Subscription("openshift-foo", "foo", "").Object, "spec", "source"); source != catalog.GetName() {
+				t.Errorf("expected subscription to use catalog source %q, got %q", catalog.GetName(), source)
+			}
+		})
+	}
+}
+
+func TestBundleRegistrySecurityContext(t *testing.T) {
+	pod, _, err := BundleRegistry("openshift-foo", "foo-operator", "quay.io/me/foo-bundle:dev", DefaultRegistryImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seccomp, _, _ := unstructured.NestedString(pod.Object, "spec", "securityContext", "seccompProfile", "type"); seccomp != "RuntimeDefault" {
+		t.Errorf("expected RuntimeDefault seccomp profile, got %q", seccomp)
+	}
+	if nonRoot, _, _ := unstructured.NestedBool(pod.Object, "spec", "securityContext", "runAsNonRoot"); !nonRoot {
+		t.Errorf("expected the registry to run as non root")
+	}
+	containers, _, _ := unstructured.NestedSlice(pod.Object, "spec", "containers")
+	container := containers[0].(map[string]interface{})
+	if escalation, _, _ := unstructured.NestedBool(container, "securityContext", "allowPrivilegeEscalation"); escalation {
+		t.Errorf("expected privilege escalation to be disallowed")
+	}
+	if drop, _, _ := unstructured.NestedStringSlice(container, "securityContext", "capabilities", "drop"); !reflect.DeepEqual(drop, []string{"ALL"}) {
+		t.Errorf("expected all capabilities to be dropped, got %v", drop)
+	}
+}
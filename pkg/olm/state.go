@@ -0,0 +1,84 @@
+package olm
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/state"
+)
+
+// State are the ClusterServiceVersion fields changed by the override, saved so they can be restored.
+type State struct {
+	Namespace     string                 `json:"namespace"`
+	Name          string                 `json:"name"`
+	Install       map[string]interface{} `json:"install"`
+	RelatedImages []interface{}          `json:"relatedImages,omitempty"`
+}
+
+// NewState records the fields of the ClusterServiceVersion the override changes.
+func NewState(csv *unstructured.Unstructured) *State {
+	state := &State{Namespace: csv.GetNamespace(), Name: csv.GetName()}
+	state.Install, _, _ = unstructured.NestedMap(csv.Object, "spec", "install")
+	state.RelatedImages, _, _ = unstructured.NestedSlice(csv.Object, "spec", "relatedImages")
+	return state
+}
+
+func (s *State) String() string {
+	return fmt.Sprintf("clusterserviceversion/%s -n %s", s.Name, s.Namespace)
+}
+
+// Restore puts the saved fields back into the ClusterServiceVersion.
+func Restore(client dynamic.Interface, state *State) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		csv, err := client.Resource(ClusterServiceVersionGVR).Namespace(state.Namespace).Get(state.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if err := unstructured.SetNestedMap(csv.Object, state.Install, "spec", "install"); err != nil {
+			return err
+		}
+		if state.RelatedImages == nil {
+			unstructured.RemoveNestedField(csv.Object, "spec", "relatedImages")
+		} else if err := unstructured.SetNestedSlice(csv.Object, state.RelatedImages, "spec", "relatedImages"); err != nil {
+			return err
+		}
+		_, err = client.Resource(ClusterServiceVersionGVR).Namespace(state.Namespace).Update(csv, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// stateKey is the key of the ClusterServiceVersion fields in the override state.
+const stateKey = "clusterserviceversion"
+
+// LoadState returns the saved ClusterServiceVersion fields, nil means the ClusterServiceVersion was not overridden.
+func LoadState(client kubernetes.Interface, operatorName string) (*State, error) {
+	s := &State{}
+	saved, err := state.Load(client, operatorName, stateKey, s)
+	if err != nil || !saved {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SaveState saves the ClusterServiceVersion fields before the override. When the same ClusterServiceVersion is overridden
+// again, the original state is kept, so the revert still goes back to the state before the first override.
+func SaveState(client kubernetes.Interface, operatorName string, s *State) error {
+	previous, err := LoadState(client, operatorName)
+	if err != nil {
+		return err
+	}
+	if previous != nil && previous.Namespace == s.Namespace && previous.Name == s.Name {
+		return nil
+	}
+	return state.Save(client, operatorName, stateKey, s)
+}
+
+// RemoveState removes the saved fields once the ClusterServiceVersion was restored.
+func RemoveState(client kubernetes.Interface, operatorName string) error {
+	return state.Remove(client, operatorName, stateKey)
+}
@@ -0,0 +1,143 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	// TODO: this should really come from discovery, but lets be lazy
+	ClusterOperatorGVR = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "clusteroperators"}
+	ClusterVersionGVR  = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "clusterversions"}
+)
+
+// conditionPollInterval is how often the clusteroperator status is checked when waiting for conditions.
+const conditionPollInterval = 2 * time.Second
+
+// Condition is a clusteroperator status condition.
+type Condition struct {
+	Type    string
+	Status  string
+	Reason  string
+	Message string
+}
+
+// ConditionExpectation describes the status a single clusteroperator condition is expected to have.
+type ConditionExpectation struct {
+	Type   string
+	Status string
+}
+
+func (e ConditionExpectation) String() string {
+	return e.Type + "=" + e.Status
+}
+
+// ParseConditionExpectations parses a comma separated list of expectations, like "Available=True,Degraded=False".
+func ParseConditionExpectations(value string) ([]ConditionExpectation, error) {
+	var result []ConditionExpectation
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("invalid condition expectation %q, must be in Type=Status format", item)
+		}
+		status := strings.TrimSpace(parts[1])
+		switch strings.ToLower(status) {
+		case "true":
+			status = "True"
+		case "false":
+			status = "False"
+		case "unknown":
+			status = "Unknown"
+		default:
+			return nil, fmt.Errorf("invalid status %q for condition %q, must be True, False or Unknown", parts[1], parts[0])
+		}
+		result = append(result, ConditionExpectation{Type: strings.TrimSpace(parts[0]), Status: status})
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("at least one condition expectation must be specified")
+	}
+	return result, nil
+}
+
+// GetConditions returns the status conditions of the given clusteroperator.
+func GetConditions(clusterOperator *unstructured.Unstructured) []Condition {
+	items, _, _ := unstructured.NestedSlice(clusterOperator.Object, "status", "conditions")
+	var conditions []Condition
+	for _, x := range items {
+		c, ok := x.(map[string]interface{})
+		if !ok {
+			continue // ignore
+		}
+		condition := Condition{}
+		condition.Type, _, _ = unstructured.NestedString(c, "type")
+		condition.Status, _, _ = unstructured.NestedString(c, "status")
+		condition.Reason, _, _ = unstructured.NestedString(c, "reason")
+		condition.Message, _, _ = unstructured.NestedString(c, "message")
+		conditions = append(conditions, condition)
+	}
+	return conditions
+}
+
+// CheckConditions returns an error describing the first condition that does not match the expectations.
+func CheckConditions(conditions []Condition, expectations []ConditionExpectation) error {
+	for _, expected := range expectations {
+		found := false
+		for _, c := range conditions {
+			if c.Type != expected.Type {
+				continue
+			}
+			found = true
+			if c.Status != expected.Status {
+				return fmt.Errorf("expected %s, got %s=%s (%s): %s", expected, c.Type, c.Status, c.Reason, c.Message)
+			}
+		}
+		if !found {
+			return fmt.Errorf("expected %s, but condition %q is not set", expected, expected.Type)
+		}
+	}
+	return nil
+}
+
+// WaitForConditions waits until the clusteroperator conditions match all expectations for the whole stable window.
+// If that does not happen within the timeout, the last offending condition is returned as an error.
+func WaitForConditions(client dynamic.Interface, name string, expectations []ConditionExpectation, stable, timeout time.Duration) error {
+	var (
+		stableSince time.Time
+		lastErr     error
+	)
+	err := wait.PollImmediate(conditionPollInterval, timeout, func() (bool, error) {
+		clusterOperator, err := client.Resource(ClusterOperatorGVR).Get(name, metav1.GetOptions{})
+		if err != nil {
+			lastErr = err
+			stableSince = time.Time{}
+			return false, nil
+		}
+		if err := CheckConditions(GetConditions(clusterOperator), expectations); err != nil {
+			lastErr = err
+			stableSince = time.Time{}
+			return false, nil
+		}
+		if stableSince.IsZero() {
+			stableSince = time.Now()
+		}
+		return time.Since(stableSince) >= stable, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		if !stableSince.IsZero() {
+			return fmt.Errorf("clusteroperator/%s conditions held only for %s within %s, need %s", name, time.Since(stableSince).Round(time.Second), timeout, stable)
+		}
+		return fmt.Errorf("clusteroperator/%s conditions not satisfied within %s: %v", name, timeout, lastErr)
+	}
+	return err
+}
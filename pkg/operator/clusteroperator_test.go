@@ -0,0 +1,72 @@
+package operator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseConditionExpectations(t *testing.T) {
+	tests := map[string]struct {
+		value       string
+		expected    []ConditionExpectation
+		expectError bool
+	}{
+		"single": {
+			value:    "Available=True",
+			expected: []ConditionExpectation{{Type: "Available", Status: "True"}},
+		},
+		"multiple with spaces and lowercase status": {
+			value:    "Available=true, Degraded=false",
+			expected: []ConditionExpectation{{Type: "Available", Status: "True"}, {Type: "Degraded", Status: "False"}},
+		},
+		"missing status": {value: "Available", expectError: true},
+		"invalid status": {value: "Available=Maybe", expectError: true},
+		"empty":          {value: "", expectError: true},
+		"missing type":   {value: "=True", expectError: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseConditionExpectations(test.value)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected error, got %#v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckConditions(t *testing.T) {
+	conditions := []Condition{
+		{Type: "Available", Status: "True"},
+		{Type: "Degraded", Status: "True", Reason: "NodeInstallerDegraded", Message: "pod crashlooping"},
+	}
+	tests := map[string]struct {
+		expectations []ConditionExpectation
+		expectError  bool
+	}{
+		"matching":  {expectations: []ConditionExpectation{{Type: "Available", Status: "True"}}},
+		"mismatch":  {expectations: []ConditionExpectation{{Type: "Degraded", Status: "False"}}, expectError: true},
+		"not found": {expectations: []ConditionExpectation{{Type: "Upgradeable", Status: "True"}}, expectError: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := CheckConditions(conditions, test.expectations)
+			if test.expectError && err == nil {
+				t.Errorf("expected error")
+			}
+			if !test.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/transaction"
+)
+
+// ComponentOverride identifies an object in the clusterversion spec.overrides list.
+type ComponentOverride struct {
+	Kind      string
+	Group     string
+	Namespace string
+	Name      string
+}
+
+// DeploymentOverride returns the component override for the given deployment.
+func DeploymentOverride(namespace, name string) ComponentOverride {
+	return WorkloadOverride(DeploymentKind, namespace, name)
+}
+
+func (c ComponentOverride) matches(override map[string]interface{}) bool {
+	kind, _, _ := unstructured.NestedString(override, "kind")
+	group, _, _ := unstructured.NestedString(override, "group")
+	ns, _, _ := unstructured.NestedString(override, "namespace")
+	name, _, _ := unstructured.NestedString(override, "name")
+	return kind == c.Kind && group == c.Group && ns == c.Namespace && name == c.Name
+}
+
+// SetUnmanaged tells the cluster version operator to stop (or start again) managing the given component.
+func SetUnmanaged(client dynamic.Interface, component ComponentOverride, unmanaged bool) error {
+	return SetUnmanagedComponents(client, unmanaged, component)
+}
+
+// UnmanagedStep returns the transaction step telling the cluster version operator to stop managing the component.
+// Undo puts back the state the component had before.
+func UnmanagedStep(client dynamic.Interface, component ComponentOverride) transaction.Step {
+	var wasUnmanaged bool
+	return transaction.Step{
+		Name: "clusterversion override",
+		Do: func(ctx context.Context) error {
+			unmanaged, err := IsUnmanaged(client, component)
+			if err != nil {
+				return err
+			}
+			wasUnmanaged = unmanaged
+			return SetUnmanaged(client, component, true)
+		},
+		Undo: func() error {
+			return SetUnmanaged(client, component, wasUnmanaged)
+		},
+	}
+}
+
+// SetUnmanagedComponents tells the cluster version operator to stop (or start again) managing all given components
+// with a single clusterversion update.
+func SetUnmanagedComponents(client dynamic.Interface, unmanaged bool, components ...ComponentOverride) error {
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		version, err := client.Resource(ClusterVersionGVR).Get("version", metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		overrides, _, err := unstructured.NestedSlice(version.Object, "spec", "overrides")
+		if err != nil {
+			return err
+		}
+		for _, component := range components {
+			overrides = setOverride(overrides, component, unmanaged)
+		}
+		if err := unstructured.SetNestedSlice(version.Object, overrides, "spec", "overrides"); err != nil {
+			return err
+		}
+
+		_, err = client.Resource(ClusterVersionGVR).Update(version, metav1.UpdateOptions{})
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to patch clusterversion/version: %v", err)
+	}
+	return nil
+}
+
+// setOverride replaces or appends the override for the component.
+func setOverride(overrides []interface{}, component ComponentOverride, unmanaged bool) []interface{} {
+	for _, x := range overrides {
+		override, ok := x.(map[string]interface{})
+		if !ok {
+			continue // ignore
+		}
+		if component.matches(override) {
+			override["unmanaged"] = unmanaged
+			return overrides
+		}
+	}
+	return append(overrides, map[string]interface{}{
+		"group":     component.Group,
+		"kind":      component.Kind,
+		"namespace": component.Namespace,
+		"name":      component.Name,
+		"unmanaged": unmanaged,
+	})
+}
+
+// HasClusterVersion returns false on clusters without the cluster version operator, eg. kind or MicroShift clusters.
+func HasClusterVersion(client dynamic.Interface) (bool, error) {
+	_, err := client.Resource(ClusterVersionGVR).Get("version", metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to get clusterversion/version: %v", err)
+	}
+	return true, nil
+}
+
+// DefaultClusterProfile is the profile of clusters installed without a cluster profile.
+const DefaultClusterProfile = "self-managed-high-availability"
+
+var featureGateGVR = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "featuregates"}
+
+// ClusterProfile returns the cluster profile the cluster version operator runs with, it selects the manifests the cluster
+// version operator applies.
+func ClusterProfile(client kubernetes.Interface) (string, error) {
+	deployment, err := client.AppsV1().Deployments("openshift-cluster-version").Get("cluster-version-operator", metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return DefaultClusterProfile, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to get the cluster version operator deployment: %v", err)
+	}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == "CLUSTER_PROFILE" && len(env.Value) > 0 {
+				return env.Value, nil
+			}
+		}
+	}
+	return DefaultClusterProfile, nil
+}
+
+// FeatureSet returns the feature set of the cluster, empty string is the default feature set.
+func FeatureSet(client dynamic.Interface) (string, error) {
+	featureGate, err := client.Resource(featureGateGVR).Get("cluster", metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to get featuregates/cluster: %v", err)
+	}
+	featureSet, _, err := unstructured.NestedString(featureGate.Object, "spec", "featureSet")
+	return featureSet, err
+}
+
+// IsUnmanaged returns true when the cluster version operator does not manage the given component.
+func IsUnmanaged(client dynamic.Interface, component ComponentOverride) (bool, error) {
+	unmanaged, err := UnmanagedComponents(client, component)
+	if err != nil {
+		return false, err
+	}
+	return len(unmanaged) > 0, nil
+}
+
+// UnmanagedComponents returns those of the given components the cluster version operator does not manage.
+func UnmanagedComponents(client dynamic.Interface, components ...ComponentOverride) ([]ComponentOverride, error) {
+	version, err := client.Resource(ClusterVersionGVR).Get("version", metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get clusterversion/version: %v", err)
+	}
+	overrides, _, err := unstructured.NestedSlice(version.Object, "spec", "overrides")
+	if err != nil {
+		return nil, err
+	}
+	var unmanaged []ComponentOverride
+	for _, component := range components {
+		if isUnmanaged(overrides, component) {
+			unmanaged = append(unmanaged, component)
+		}
+	}
+	return unmanaged, nil
+}
+
+func isUnmanaged(overrides []interface{}, component ComponentOverride) bool {
+	for _, x := range overrides {
+		override, ok := x.(map[string]interface{})
+		if !ok || !component.matches(override) {
+			continue
+		}
+		unmanaged, _, _ := unstructured.NestedBool(override, "unmanaged")
+		return unmanaged
+	}
+	return false
+}
+
+// ObjectOverride returns the component override for the given object.
+func ObjectOverride(obj *unstructured.Unstructured) ComponentOverride {
+	gvk := obj.GroupVersionKind()
+	if gvk.Group == "apps" && (gvk.Kind == DeploymentKind || gvk.Kind == DaemonSetKind || gvk.Kind == StatefulSetKind) {
+		return WorkloadOverride(gvk.Kind, obj.GetNamespace(), obj.GetName())
+	}
+	return ComponentOverride{Kind: gvk.Kind, Group: gvk.Group, Namespace: obj.GetNamespace(), Name: obj.GetName()}
+}
@@ -0,0 +1,70 @@
+package operator
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_setOverride(t *testing.T) {
+	component := DeploymentOverride("openshift-kube-apiserver-operator", "kube-apiserver-operator")
+
+	overrides := setOverride(nil, component, true)
+	if len(overrides) != 1 {
+		t.Fatalf("expected override to be appended, got %#v", overrides)
+	}
+	if unmanaged := overrides[0].(map[string]interface{})["unmanaged"]; unmanaged != true {
+		t.Errorf("expected override to be unmanaged, got %v", unmanaged)
+	}
+
+	other := DeploymentOverride("openshift-etcd-operator", "etcd-operator")
+	overrides = setOverride(overrides, other, true)
+	overrides = setOverride(overrides, component, false)
+	if len(overrides) != 2 {
+		t.Fatalf("expected existing override to be replaced, got %#v", overrides)
+	}
+	if unmanaged := overrides[0].(map[string]interface{})["unmanaged"]; unmanaged != false {
+		t.Errorf("expected override to be managed, got %v", unmanaged)
+	}
+	if unmanaged := overrides[1].(map[string]interface{})["unmanaged"]; unmanaged != true {
+		t.Errorf("expected other override to stay unmanaged, got %v", unmanaged)
+	}
+}
+
+func Test_isUnmanaged(t *testing.T) {
+	component := DeploymentOverride("openshift-kube-apiserver-operator", "kube-apiserver-operator")
+	if isUnmanaged(nil, component) {
+		t.Errorf("expected component without override to be managed")
+	}
+	if !isUnmanaged(setOverride(nil, component, true), component) {
+		t.Errorf("expected component to be unmanaged")
+	}
+	if isUnmanaged(setOverride(nil, component, false), component) {
+		t.Errorf("expected component with managed override to be managed")
+	}
+}
+
+func TestObjectOverride(t *testing.T) {
+	object := func(apiVersion, kind string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"namespace": "openshift-dns", "name": "foo"},
+		}}
+	}
+	tests := []struct {
+		object   *unstructured.Unstructured
+		expected ComponentOverride
+	}{
+		{object: object("apps/v1", "Deployment"), expected: DeploymentOverride("openshift-dns", "foo")},
+		{object: object("apps/v1", "DaemonSet"), expected: WorkloadOverride(DaemonSetKind, "openshift-dns", "foo")},
+		{object: object("v1", "ConfigMap"), expected: ComponentOverride{Kind: "ConfigMap", Namespace: "openshift-dns", Name: "foo"}},
+	}
+	for _, test := range tests {
+		t.Run(test.object.GetKind(), func(t *testing.T) {
+			if got := ObjectOverride(test.object); got != test.expected {
+				t.Errorf("expected %#v, got %#v", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetOperatorNamespace guess the namespace where the operator is being deployed.
+// TODO: This should not be necessary and we should have this information as related object in clusteroperator/foo
+func GetOperatorNamespace(operatorName string) string {
+	operatorNamespace := strings.TrimPrefix(operatorName, "openshift-")
+	switch operatorName {
+	case "insights":
+		return "openshift-insights"
+	case "openshift-apiserver":
+		return "openshift-apiserver-operator"
+	default:
+		return "openshift-" + operatorNamespace + "-operator"
+	}
+}
+
+// GetOperatorDeploymentName guess the deployment name of the operator.
+// TODO: This should not be necessary and we should have this information as related object in clusteroperator/foo
+func GetOperatorDeploymentName(operatorName string) string {
+	return operatorName + "-operator"
+}
+
+// ResolveDeployment returns the operator deployment. When the deployment name is not given, it is guessed from the operator
+// name and if there is no such deployment, the only deployment in the operator namespace is used.
+func ResolveDeployment(client kubernetes.Interface, operatorName, deploymentName string) (*appsv1.Deployment, error) {
+	if len(deploymentName) == 0 {
+		deploymentName = GetOperatorDeploymentName(operatorName)
+	}
+	deploymentNS := GetOperatorNamespace(operatorName)
+	deployment, err := client.AppsV1().Deployments(deploymentNS).Get(deploymentName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		deployments, err := client.AppsV1().Deployments(deploymentNS).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get deployments in namespace %s: %v", deploymentNS, err)
+		}
+		if len(deployments.Items) != 1 {
+			return nil, fmt.Errorf("deployment %s/%s not found. Maybe try --deployment for a custom name", deploymentNS, deploymentName)
+		}
+		return &deployments.Items[0], nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get deployment  %s/%s: %v", deploymentNS, deploymentName, err)
+	}
+	return deployment, nil
+}
+
+// SetOperatorImage sets the image of all operator containers and init containers, including the OPERATOR_IMAGE
+// environment variable operators use to reference their own image.
+func SetOperatorImage(spec *corev1.PodSpec, image string) {
+	for i := range spec.Containers {
+		spec.Containers[i].Image = image
+		for j, ev := range spec.Containers[i].Env {
+			if ev.Name == "OPERATOR_IMAGE" {
+				spec.Containers[i].Env[j].Value = image
+			}
+		}
+	}
+	for i := range spec.InitContainers {
+		spec.InitContainers[i].Image = image
+	}
+}
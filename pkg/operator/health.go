@@ -0,0 +1,276 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// healthPollInterval is how often the operator pods and conditions are checked when watching the operator health.
+const healthPollInterval = 5 * time.Second
+
+// deploymentRevisionAnnotation is the revision of the deployment and of its replica sets.
+const deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+
+// crashReasons are the container waiting reasons meaning the new pod will not become ready on its own.
+var crashReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"CreateContainerConfigError": true,
+}
+
+// WatchWorkloadHealth watches the new pods of the deployment, daemon set or stateful set and the clusteroperator
+// conditions for the whole window. It fails as soon as a new pod crashes or the operator turns Degraded=True, or when
+// the new pods are not ready at the end of the window. The conditions are the clusteroperator conditions before the
+// override, an operator already Degraded fails only when it recovers and turns Degraded again. The conditions are not
+// checked when the operator name is empty.
+func WatchWorkloadHealth(ctx context.Context, dynamicClient dynamic.Interface, kubeClient kubernetes.Interface, operatorName string, conditions []Condition, kind, namespace, name string, window time.Duration) error {
+	deadline := time.Now().Add(window)
+	for {
+		rolledOut, pods, err := workloadPods(kubeClient, kind, namespace, name)
+		if err == nil {
+			if err := checkPods(pods); err != nil {
+				return err
+			}
+		}
+		if len(operatorName) > 0 {
+			clusterOperator, err := dynamicClient.Resource(ClusterOperatorGVR).Get(operatorName, metav1.GetOptions{})
+			if err == nil {
+				current := GetConditions(clusterOperator)
+				if err := checkNotDegraded(conditions, current); err != nil {
+					return fmt.Errorf("clusteroperator/%s %v", operatorName, err)
+				}
+				conditions = current
+			}
+		}
+
+		if !time.Now().Before(deadline) {
+			if !rolledOut || !podsReady(pods) {
+				return fmt.Errorf("%s pods did not become ready within %s", WorkloadString(kind, namespace, name), window)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(healthPollInterval):
+		}
+	}
+}
+
+// workloadPods returns whether the workload is rolled out and the pods of its current pod template which are not being
+// deleted. The pods of the previous template are left out, no pods are returned until the workload controller creates
+// the current revision.
+func workloadPods(client kubernetes.Interface, kind, namespace, name string) (bool, []corev1.Pod, error) {
+	var rolledOut bool
+	var labelSelector *metav1.LabelSelector
+	var hashLabel, hash string
+	switch kind {
+	case DaemonSetKind:
+		daemonSet, err := client.AppsV1().DaemonSets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil, err
+		}
+		rolledOut, labelSelector = daemonSetRolledOut(daemonSet), daemonSet.Spec.Selector
+		if daemonSet.Status.ObservedGeneration < daemonSet.Generation {
+			return false, nil, nil
+		}
+		hashLabel = appsv1.DefaultDaemonSetUniqueLabelKey
+		if hash, err = daemonSetRevisionHash(client, daemonSet, labelSelector); err != nil {
+			return false, nil, err
+		}
+	case StatefulSetKind:
+		statefulSet, err := client.AppsV1().StatefulSets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil, err
+		}
+		rolledOut, labelSelector = statefulSetRolledOut(statefulSet), statefulSet.Spec.Selector
+		if statefulSet.Status.ObservedGeneration < statefulSet.Generation {
+			return false, nil, nil
+		}
+		hashLabel, hash = appsv1.StatefulSetRevisionLabel, statefulSet.Status.UpdateRevision
+	default:
+		deployment, err := client.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil, err
+		}
+		rolledOut, labelSelector = deploymentRolledOut(deployment), deployment.Spec.Selector
+		if deployment.Status.ObservedGeneration < deployment.Generation {
+			return false, nil, nil
+		}
+		hashLabel = appsv1.DefaultDeploymentUniqueLabelKey
+		if hash, err = replicaSetTemplateHash(client, deployment, labelSelector); err != nil {
+			return false, nil, err
+		}
+	}
+	if len(hash) == 0 {
+		return false, nil, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return false, nil, err
+	}
+	pods, err := client.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return false, nil, err
+	}
+	return rolledOut, currentPods(pods.Items, hashLabel, hash), nil
+}
+
+// currentPods returns the pods with the hash of the current pod template which are not being deleted.
+func currentPods(pods []corev1.Pod, hashLabel, hash string) []corev1.Pod {
+	var current []corev1.Pod
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil && pod.Labels[hashLabel] == hash {
+			current = append(current, pod)
+		}
+	}
+	return current
+}
+
+// replicaSetTemplateHash returns the pod template hash of the deployment replica set with the current revision, empty
+// when the replica set was not created yet.
+func replicaSetTemplateHash(client kubernetes.Interface, deployment *appsv1.Deployment, labelSelector *metav1.LabelSelector) (string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return "", err
+	}
+	replicaSets, err := client.AppsV1().ReplicaSets(deployment.Namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return "", err
+	}
+	revision := deployment.Annotations[deploymentRevisionAnnotation]
+	for _, rs := range replicaSets.Items {
+		if metav1.IsControlledBy(&rs, deployment) && len(revision) > 0 && rs.Annotations[deploymentRevisionAnnotation] == revision {
+			return rs.Labels[appsv1.DefaultDeploymentUniqueLabelKey], nil
+		}
+	}
+	return "", nil
+}
+
+// daemonSetRevisionHash returns the hash of the newest controller revision of the daemon set, empty when there is no
+// controller revision yet.
+func daemonSetRevisionHash(client kubernetes.Interface, daemonSet *appsv1.DaemonSet, labelSelector *metav1.LabelSelector) (string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return "", err
+	}
+	revisions, err := client.AppsV1().ControllerRevisions(daemonSet.Namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return "", err
+	}
+	var newest *appsv1.ControllerRevision
+	for i := range revisions.Items {
+		if !metav1.IsControlledBy(&revisions.Items[i], daemonSet) {
+			continue
+		}
+		if newest == nil || revisions.Items[i].Revision > newest.Revision {
+			newest = &revisions.Items[i]
+		}
+	}
+	if newest == nil {
+		return "", nil
+	}
+	return newest.Labels[appsv1.DefaultDaemonSetUniqueLabelKey], nil
+}
+
+// checkPods returns an error when any of the pod containers crashes.
+func checkPods(pods []corev1.Pod) error {
+	for _, pod := range pods {
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if status.State.Waiting != nil && crashReasons[status.State.Waiting.Reason] {
+				return fmt.Errorf("pod %s/%s container %q is in %s: %s", pod.Namespace, pod.Name, status.Name, status.State.Waiting.Reason, status.State.Waiting.Message)
+			}
+		}
+	}
+	return nil
+}
+
+// checkNotDegraded returns an error when the operator turned Degraded=True since the previous conditions.
+func checkNotDegraded(previous, conditions []Condition) error {
+	if isDegraded(previous) {
+		return nil
+	}
+	for _, c := range conditions {
+		if c.Type == "Degraded" && c.Status == "True" {
+			return fmt.Errorf("is Degraded (%s): %s", c.Reason, c.Message)
+		}
+	}
+	return nil
+}
+
+func isDegraded(conditions []Condition) bool {
+	for _, c := range conditions {
+		if c.Type == "Degraded" && c.Status == "True" {
+			return true
+		}
+	}
+	return false
+}
+
+func podsReady(pods []corev1.Pod) bool {
+	if len(pods) == 0 {
+		return false
+	}
+	for _, pod := range pods {
+		ready := false
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
+				ready = true
+			}
+		}
+		if !ready {
+			return false
+		}
+	}
+	return true
+}
+
+// SavePodLogs saves the logs of the workload pods that are not ready or restarted into the directory. For restarted
+// containers the logs of the previous (crashed) container are saved as well. It returns the files written.
+func SavePodLogs(client kubernetes.Interface, kind, namespace, name, dir string) ([]string, error) {
+	_, pods, err := workloadPods(client, kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, pod := range pods {
+		if podsReady([]corev1.Pod{pod}) {
+			continue
+		}
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			logs := map[string]bool{fmt.Sprintf("%s-%s.log", pod.Name, status.Name): false}
+			if status.RestartCount > 0 {
+				logs[fmt.Sprintf("%s-%s-previous.log", pod.Name, status.Name)] = true
+			}
+			for file, previous := range logs {
+				data, err := client.CoreV1().Pods(namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Container: status.Name, Previous: previous}).Do().Raw()
+				if err != nil {
+					continue // the container might not have started at all
+				}
+				path := filepath.Join(dir, file)
+				if err := ioutil.WriteFile(path, data, 0644); err != nil {
+					return files, err
+				}
+				files = append(files, path)
+			}
+		}
+	}
+	return files, nil
+}
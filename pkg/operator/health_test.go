@@ -0,0 +1,65 @@
+package operator
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_checkPods(t *testing.T) {
+	waiting := func(reason string) corev1.Pod {
+		return corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "operator", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}}},
+		}}}
+	}
+	tests := []struct {
+		name      string
+		pods      []corev1.Pod
+		expectErr bool
+	}{
+		{name: "no pods"},
+		{name: "starting", pods: []corev1.Pod{waiting("ContainerCreating")}},
+		{name: "crashing", pods: []corev1.Pod{waiting("ContainerCreating"), waiting("CrashLoopBackOff")}, expectErr: true},
+		{name: "bad image", pods: []corev1.Pod{waiting("ImagePullBackOff")}, expectErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := checkPods(test.pods); (err != nil) != test.expectErr {
+				t.Errorf("expected error %v, got %v", test.expectErr, err)
+			}
+		})
+	}
+}
+
+func Test_checkNotDegraded(t *testing.T) {
+	healthy := []Condition{{Type: "Degraded", Status: "False"}, {Type: "Available", Status: "True"}}
+	degraded := []Condition{{Type: "Degraded", Status: "True", Reason: "Crash"}}
+	if err := checkNotDegraded(healthy, healthy); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := checkNotDegraded(healthy, degraded); err == nil {
+		t.Errorf("expected degraded operator to fail")
+	}
+	if err := checkNotDegraded(degraded, degraded); err != nil {
+		t.Errorf("expected operator degraded before the override to pass, got %v", err)
+	}
+	if err := checkNotDegraded(nil, degraded); err == nil {
+		t.Errorf("expected degraded operator without previous conditions to fail")
+	}
+}
+
+func Test_currentPods(t *testing.T) {
+	pod := func(name, hash string, deleted bool) corev1.Pod {
+		p := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: hash}}}
+		if deleted {
+			p.DeletionTimestamp = &metav1.Time{}
+		}
+		return p
+	}
+	pods := currentPods([]corev1.Pod{pod("old", "abc", false), pod("new", "def", false), pod("terminating", "def", true)}, appsv1.DefaultDeploymentUniqueLabelKey, "def")
+	if len(pods) != 1 || pods[0].Name != "new" {
+		t.Errorf("expected only the new pod, got %v", pods)
+	}
+}
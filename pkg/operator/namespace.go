@@ -0,0 +1,29 @@
+package operator
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PluginNamespace is the namespace owned by the plugin, where it keeps its cluster state.
+const PluginNamespace = "openshift-operator-dev"
+
+// EnsurePluginNamespace creates the plugin namespace when it does not exist yet.
+func EnsurePluginNamespace(client kubernetes.Interface) error {
+	_, err := client.CoreV1().Namespaces().Get(PluginNamespace, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return fmt.Errorf("unable to get namespace %q: %v", PluginNamespace, err)
+	}
+	_, err = client.CoreV1().Namespaces().Create(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: PluginNamespace}})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("unable to create namespace %q: %v", PluginNamespace, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package operator
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+// operatorConfigGroup is the API group of the resources configuring the OpenShift operators (eg. kubeapiservers/cluster).
+const operatorConfigGroup = "operator.openshift.io"
+
+// OperatorConfig is the operator.openshift.io resource configuring the operator.
+type OperatorConfig struct {
+	GVR  schema.GroupVersionResource
+	Kind string
+	Name string
+}
+
+// GetOperatorConfig finds the operator.openshift.io resource in the clusteroperator related objects.
+func GetOperatorConfig(clusterOperator *unstructured.Unstructured, mapper meta.RESTMapper) (*OperatorConfig, error) {
+	for _, related := range GetRelatedObjects(clusterOperator) {
+		if related.Group != operatorConfigGroup || len(related.Namespace) > 0 {
+			continue
+		}
+		gvr, err := related.GroupVersionResource(mapper)
+		if err != nil {
+			return nil, err
+		}
+		gvk, err := mapper.KindFor(gvr)
+		if err != nil {
+			return nil, err
+		}
+		return &OperatorConfig{GVR: gvr, Kind: gvk.Kind, Name: related.Name}, nil
+	}
+	return nil, fmt.Errorf("clusteroperator/%s does not list any %s resource in related objects", clusterOperator.GetName(), operatorConfigGroup)
+}
+
+// ComponentOverride returns the clusterversion override for the operator config resource.
+func (c *OperatorConfig) ComponentOverride() ComponentOverride {
+	return ComponentOverride{Kind: c.Kind, Group: c.GVR.Group, Name: c.Name}
+}
+
+// Update applies the mutation to the operator config resource, retrying on conflicts.
+func (c *OperatorConfig) Update(client dynamic.Interface, mutate func(obj *unstructured.Unstructured) error) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		obj, err := client.Resource(c.GVR).Get(c.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if err := mutate(obj); err != nil {
+			return err
+		}
+		_, err = client.Resource(c.GVR).Update(obj, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// SetManagementState sets the spec.managementState of the operator config resource and returns the previous state.
+func (c *OperatorConfig) SetManagementState(client dynamic.Interface, state string) (string, error) {
+	previous := ""
+	err := c.Update(client, func(obj *unstructured.Unstructured) error {
+		previous, _, _ = unstructured.NestedString(obj.Object, "spec", "managementState")
+		return unstructured.SetNestedField(obj.Object, state, "spec", "managementState")
+	})
+	return previous, err
+}
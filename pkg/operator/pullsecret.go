@@ -0,0 +1,76 @@
+package operator
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// PullSecretName is the secret with the credentials to pull the override images.
+	PullSecretName = "operator-dev-pull-secret"
+
+	// PullSecretLabel marks the pull secret created by the override, secrets with the same name created by somebody
+	// else are never changed or removed.
+	PullSecretLabel = "operator-dev.openshift.io/pull-secret"
+)
+
+// IsOverridePullSecret returns true when the secret was created by the override.
+func IsOverridePullSecret(secret *corev1.Secret) bool {
+	return secret.Labels[PullSecretLabel] == "true"
+}
+
+// AddImagePullSecret adds the secret to the pod spec image pull secrets, unless it is already there.
+func AddImagePullSecret(spec *corev1.PodSpec, name string) {
+	for _, ref := range spec.ImagePullSecrets {
+		if ref.Name == name {
+			return
+		}
+	}
+	spec.ImagePullSecrets = append(spec.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+}
+
+// RemoveImagePullSecret returns false when the pod spec does not reference the secret.
+func RemoveImagePullSecret(spec *corev1.PodSpec, name string) bool {
+	for i, ref := range spec.ImagePullSecrets {
+		if ref.Name == name {
+			spec.ImagePullSecrets = append(spec.ImagePullSecrets[:i], spec.ImagePullSecrets[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// RemovePullSecret removes the pull secret created by the override from the workload and its namespace. It returns false
+// when there was no pull secret created by the override.
+func RemovePullSecret(client kubernetes.Interface, kind, namespace, name string) (bool, error) {
+	secret, err := client.CoreV1().Secrets(namespace).Get(PullSecretName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !IsOverridePullSecret(secret) {
+		return false, nil
+	}
+	template, err := GetPodTemplate(client, kind, namespace, name)
+	if err != nil {
+		return false, err
+	}
+	if RemoveImagePullSecret(&template.Spec, PullSecretName) {
+		if _, err := UpdatePodTemplate(client, kind, namespace, name, func(template *corev1.PodTemplateSpec) error {
+			RemoveImagePullSecret(&template.Spec, PullSecretName)
+			return nil
+		}); err != nil {
+			return false, fmt.Errorf("unable to remove pull secret from %s: %v", WorkloadString(kind, namespace, name), err)
+		}
+	}
+	if err := client.CoreV1().Secrets(namespace).Delete(PullSecretName, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return false, fmt.Errorf("unable to delete pull secret: %v", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,42 @@
+package operator
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// RelatedObject is a reference to an object listed in the clusteroperator status.relatedObjects.
+type RelatedObject struct {
+	Group     string
+	Resource  string
+	Namespace string
+	Name      string
+}
+
+// GetRelatedObjects returns the objects the given clusteroperator reports as related.
+func GetRelatedObjects(clusterOperator *unstructured.Unstructured) []RelatedObject {
+	items, _, _ := unstructured.NestedSlice(clusterOperator.Object, "status", "relatedObjects")
+	var result []RelatedObject
+	for _, x := range items {
+		r, ok := x.(map[string]interface{})
+		if !ok {
+			continue // ignore
+		}
+		object := RelatedObject{}
+		object.Group, _, _ = unstructured.NestedString(r, "group")
+		object.Resource, _, _ = unstructured.NestedString(r, "resource")
+		object.Namespace, _, _ = unstructured.NestedString(r, "namespace")
+		object.Name, _, _ = unstructured.NestedString(r, "name")
+		if len(object.Resource) == 0 || len(object.Name) == 0 {
+			continue
+		}
+		result = append(result, object)
+	}
+	return result
+}
+
+// GroupVersionResource resolves the preferred version of the related object resource.
+func (r RelatedObject) GroupVersionResource(mapper meta.RESTMapper) (schema.GroupVersionResource, error) {
+	return mapper.ResourceFor(schema.GroupVersionResource{Group: r.Group, Resource: r.Resource})
+}
@@ -0,0 +1,147 @@
+package operator
+
+import (
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// rolloutPollInterval is how often the workload status is checked when waiting for rollout.
+const rolloutPollInterval = 2 * time.Second
+
+// WaitForDeploymentRollout waits until all replicas of the deployment run the latest pod template and are available.
+func WaitForDeploymentRollout(client kubernetes.Interface, namespace, name string, timeout time.Duration) error {
+	err := wait.PollImmediate(rolloutPollInterval, timeout, func() (bool, error) {
+		deployment, err := client.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return deploymentRolledOut(deployment), nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("deployment %s/%s did not roll out within %s", namespace, name, timeout)
+	}
+	return err
+}
+
+// WaitForDaemonSetRollout waits until the daemon set pods on all nodes run the latest pod template and are available.
+func WaitForDaemonSetRollout(client kubernetes.Interface, namespace, name string, timeout time.Duration) error {
+	err := wait.PollImmediate(rolloutPollInterval, timeout, func() (bool, error) {
+		daemonSet, err := client.AppsV1().DaemonSets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return daemonSetRolledOut(daemonSet), nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("daemonset %s/%s did not roll out within %s", namespace, name, timeout)
+	}
+	return err
+}
+
+// WaitForStatefulSetRollout waits until all replicas of the stateful set run the latest revision and are ready.
+func WaitForStatefulSetRollout(client kubernetes.Interface, namespace, name string, timeout time.Duration) error {
+	err := wait.PollImmediate(rolloutPollInterval, timeout, func() (bool, error) {
+		statefulSet, err := client.AppsV1().StatefulSets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return statefulSetRolledOut(statefulSet), nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("statefulset %s/%s did not roll out within %s", namespace, name, timeout)
+	}
+	return err
+}
+
+// WaitForRollout waits for the rollout of the deployment, daemon set or stateful set.
+func WaitForRollout(client kubernetes.Interface, kind, namespace, name string, timeout time.Duration) error {
+	switch kind {
+	case DaemonSetKind:
+		return WaitForDaemonSetRollout(client, namespace, name, timeout)
+	case StatefulSetKind:
+		return WaitForStatefulSetRollout(client, namespace, name, timeout)
+	default:
+		return WaitForDeploymentRollout(client, namespace, name, timeout)
+	}
+}
+
+func deploymentRolledOut(deployment *appsv1.Deployment) bool {
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return false
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	return deployment.Status.UpdatedReplicas == replicas &&
+		deployment.Status.Replicas == replicas &&
+		deployment.Status.AvailableReplicas == replicas
+}
+
+func daemonSetRolledOut(daemonSet *appsv1.DaemonSet) bool {
+	if daemonSet.Status.ObservedGeneration < daemonSet.Generation {
+		return false
+	}
+	return daemonSet.Status.UpdatedNumberScheduled == daemonSet.Status.DesiredNumberScheduled &&
+		daemonSet.Status.NumberAvailable == daemonSet.Status.DesiredNumberScheduled
+}
+
+func statefulSetRolledOut(statefulSet *appsv1.StatefulSet) bool {
+	if statefulSet.Status.ObservedGeneration < statefulSet.Generation {
+		return false
+	}
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	return statefulSet.Status.UpdateRevision == statefulSet.Status.CurrentRevision &&
+		statefulSet.Status.UpdatedReplicas == replicas &&
+		statefulSet.Status.ReadyReplicas == replicas
+}
+
+// RestartDeploymentPods deletes the deployment pods and waits until they are replaced by new available pods.
+// Unlike changing the pod template, this does not modify the deployment spec, so it is not reverted by the cluster version operator.
+func RestartDeploymentPods(client kubernetes.Interface, deployment *appsv1.Deployment, timeout time.Duration) error {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	pods, err := client.CoreV1().Pods(deployment.Namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return err
+	}
+	oldPods := map[string]bool{}
+	for _, pod := range pods.Items {
+		oldPods[string(pod.UID)] = true
+		if err := client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+	}
+
+	err = wait.PollImmediate(rolloutPollInterval, timeout, func() (bool, error) {
+		pods, err := client.CoreV1().Pods(deployment.Namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return false, nil
+		}
+		for _, pod := range pods.Items {
+			if oldPods[string(pod.UID)] {
+				return false, nil
+			}
+		}
+		current, err := client.AppsV1().Deployments(deployment.Namespace).Get(deployment.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		return deploymentRolledOut(current), nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("deployment %s/%s pods were not replaced within %s", deployment.Namespace, deployment.Name, timeout)
+	}
+	return err
+}
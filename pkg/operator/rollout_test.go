@@ -0,0 +1,79 @@
+package operator
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_deploymentRolledOut(t *testing.T) {
+	replicas := int32(2)
+	newDeployment := func(observedGeneration int64, updated, total, available int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Generation: 2},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1.DeploymentStatus{
+				ObservedGeneration: observedGeneration,
+				UpdatedReplicas:    updated,
+				Replicas:           total,
+				AvailableReplicas:  available,
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		deployment *appsv1.Deployment
+		expected   bool
+	}{
+		"rolled out":              {deployment: newDeployment(2, 2, 2, 2), expected: true},
+		"generation not observed": {deployment: newDeployment(1, 2, 2, 2)},
+		"old replicas running":    {deployment: newDeployment(2, 1, 3, 2)},
+		"not available":           {deployment: newDeployment(2, 2, 2, 1)},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := deploymentRolledOut(test.deployment); got != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func Test_daemonSetRolledOut(t *testing.T) {
+	daemonSet := &appsv1.DaemonSet{
+		Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, UpdatedNumberScheduled: 2, NumberAvailable: 3},
+	}
+	if daemonSetRolledOut(daemonSet) {
+		t.Errorf("expected daemonset with pods running old template not to be rolled out")
+	}
+	daemonSet.Status.UpdatedNumberScheduled = 3
+	if !daemonSetRolledOut(daemonSet) {
+		t.Errorf("expected daemonset to be rolled out")
+	}
+}
+
+func Test_statefulSetRolledOut(t *testing.T) {
+	replicas := int32(3)
+	statefulSet := &appsv1.StatefulSet{
+		Spec: appsv1.StatefulSetSpec{Replicas: &replicas},
+		Status: appsv1.StatefulSetStatus{
+			CurrentRevision: "foo-1",
+			UpdateRevision:  "foo-2",
+			UpdatedReplicas: 3,
+			ReadyReplicas:   3,
+		},
+	}
+	if statefulSetRolledOut(statefulSet) {
+		t.Errorf("expected statefulset with pods running old revision not to be rolled out")
+	}
+	statefulSet.Status.CurrentRevision = "foo-2"
+	if !statefulSetRolledOut(statefulSet) {
+		t.Errorf("expected statefulset to be rolled out")
+	}
+	statefulSet.Status.ReadyReplicas = 2
+	if statefulSetRolledOut(statefulSet) {
+		t.Errorf("expected statefulset with pods not ready not to be rolled out")
+	}
+}
@@ -0,0 +1,168 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// staticPodOperator describes where an operator installing static pods on the master nodes keeps its revisions.
+type staticPodOperator struct {
+	// resource is the operator.openshift.io resource with the node statuses (the name is always "cluster")
+	resource string
+	// namespace is the operand namespace with the revisioned config maps
+	namespace string
+	// podConfigMap is the prefix of the revisioned config map with the static pod manifest
+	podConfigMap string
+}
+
+var staticPodOperators = map[string]staticPodOperator{
+	"kube-apiserver":          {resource: "kubeapiservers", namespace: "openshift-kube-apiserver", podConfigMap: "kube-apiserver-pod"},
+	"kube-controller-manager": {resource: "kubecontrollermanagers", namespace: "openshift-kube-controller-manager", podConfigMap: "kube-controller-manager-pod"},
+	"kube-scheduler":          {resource: "kubeschedulers", namespace: "openshift-kube-scheduler", podConfigMap: "kube-scheduler-pod"},
+	"etcd":                    {resource: "etcds", namespace: "openshift-etcd", podConfigMap: "etcd-pod"},
+}
+
+// IsStaticPodOperator returns true when the operator rolls out its operand as static pods in revisions.
+func IsStaticPodOperator(operatorName string) bool {
+	_, ok := staticPodOperators[operatorName]
+	return ok
+}
+
+// NodeStatus is the revision status of a single master node.
+type NodeStatus struct {
+	NodeName        string
+	CurrentRevision int64
+	TargetRevision  int64
+}
+
+// RevisionStatus is the revision status of a static pod operator.
+type RevisionStatus struct {
+	LatestAvailableRevision int64
+	Nodes                   []NodeStatus
+}
+
+// RolledOut returns true when all nodes run the given revision or newer.
+func (s *RevisionStatus) RolledOut(revision int64) bool {
+	if len(s.Nodes) == 0 {
+		return false
+	}
+	for _, n := range s.Nodes {
+		if n.CurrentRevision < revision {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *RevisionStatus) String() string {
+	var nodes []string
+	for _, n := range s.Nodes {
+		if n.TargetRevision > 0 && n.TargetRevision != n.CurrentRevision {
+			nodes = append(nodes, fmt.Sprintf("%s: %d -> %d", n.NodeName, n.CurrentRevision, n.TargetRevision))
+			continue
+		}
+		nodes = append(nodes, fmt.Sprintf("%s: %d", n.NodeName, n.CurrentRevision))
+	}
+	return fmt.Sprintf("latest revision %d (%s)", s.LatestAvailableRevision, strings.Join(nodes, ", "))
+}
+
+// GetRevisionStatus reads the revision status of the static pod operator from its operator.openshift.io resource.
+func GetRevisionStatus(client dynamic.Interface, operatorName string) (*RevisionStatus, error) {
+	staticPod, ok := staticPodOperators[operatorName]
+	if !ok {
+		return nil, fmt.Errorf("operator %q does not manage static pods", operatorName)
+	}
+	gvr := schema.GroupVersionResource{Group: operatorConfigGroup, Version: "v1", Resource: staticPod.resource}
+	obj, err := client.Resource(gvr).Get("cluster", metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get %s/cluster: %v", staticPod.resource, err)
+	}
+	return revisionStatusFrom(obj), nil
+}
+
+func revisionStatusFrom(obj *unstructured.Unstructured) *RevisionStatus {
+	status := &RevisionStatus{}
+	status.LatestAvailableRevision, _, _ = unstructured.NestedInt64(obj.Object, "status", "latestAvailableRevision")
+	nodeStatuses, _, _ := unstructured.NestedSlice(obj.Object, "status", "nodeStatuses")
+	for _, x := range nodeStatuses {
+		n, ok := x.(map[string]interface{})
+		if !ok {
+			continue // ignore
+		}
+		node := NodeStatus{}
+		node.NodeName, _, _ = unstructured.NestedString(n, "nodeName")
+		node.CurrentRevision, _, _ = unstructured.NestedInt64(n, "currentRevision")
+		node.TargetRevision, _, _ = unstructured.NestedInt64(n, "targetRevision")
+		status.Nodes = append(status.Nodes, node)
+	}
+	return status
+}
+
+// FindRevisionWithImage returns the newest revision after the given one whose static pod manifest references the image.
+// When the image is empty, the newest revision after the given one is returned. Zero is returned when there is no such revision.
+func FindRevisionWithImage(client kubernetes.Interface, operatorName string, after, latest int64, image string) (int64, error) {
+	staticPod, ok := staticPodOperators[operatorName]
+	if !ok {
+		return 0, fmt.Errorf("operator %q does not manage static pods", operatorName)
+	}
+	for revision := latest; revision > after; revision-- {
+		if len(image) == 0 {
+			return revision, nil
+		}
+		configMap, err := client.CoreV1().ConfigMaps(staticPod.namespace).Get(fmt.Sprintf("%s-%d", staticPod.podConfigMap, revision), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue // pruned revision
+		}
+		if err != nil {
+			return 0, err
+		}
+		if strings.Contains(configMap.Data["pod.yaml"], image) {
+			return revision, nil
+		}
+	}
+	return 0, nil
+}
+
+// WaitForRevision waits until a revision newer than the given one, which references the image, is rolled out to all nodes.
+// The progress function is called every time the revision status changes.
+func WaitForRevision(dynamicClient dynamic.Interface, kubeClient kubernetes.Interface, operatorName string, after int64, image string, timeout time.Duration, progress func(*RevisionStatus)) (int64, error) {
+	var (
+		revision   int64
+		lastStatus string
+	)
+	err := wait.PollImmediate(rolloutPollInterval, timeout, func() (bool, error) {
+		status, err := GetRevisionStatus(dynamicClient, operatorName)
+		if err != nil {
+			return false, nil
+		}
+		if s := status.String(); s != lastStatus {
+			lastStatus = s
+			progress(status)
+		}
+		if revision == 0 {
+			if revision, err = FindRevisionWithImage(kubeClient, operatorName, after, status.LatestAvailableRevision, image); err != nil {
+				return false, nil
+			}
+			if revision == 0 {
+				return false, nil
+			}
+		}
+		return status.RolledOut(revision), nil
+	})
+	if err == wait.ErrWaitTimeout {
+		if revision == 0 {
+			return 0, fmt.Errorf("no new revision of operator %q referencing %q created within %s", operatorName, image, timeout)
+		}
+		return revision, fmt.Errorf("revision %d of operator %q not rolled out to all nodes within %s (%s)", revision, operatorName, timeout, lastStatus)
+	}
+	return revision, err
+}
@@ -0,0 +1,36 @@
+package operator
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_revisionStatusFrom(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"latestAvailableRevision": int64(8),
+			"nodeStatuses": []interface{}{
+				map[string]interface{}{"nodeName": "master-0", "currentRevision": int64(8)},
+				map[string]interface{}{"nodeName": "master-1", "currentRevision": int64(7), "targetRevision": int64(8)},
+			},
+		},
+	}}
+
+	status := revisionStatusFrom(obj)
+	if status.LatestAvailableRevision != 8 {
+		t.Errorf("expected latest available revision 8, got %d", status.LatestAvailableRevision)
+	}
+	if len(status.Nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %#v", status.Nodes)
+	}
+	if expected := "latest revision 8 (master-0: 8, master-1: 7 -> 8)"; status.String() != expected {
+		t.Errorf("expected %q, got %q", expected, status.String())
+	}
+	if status.RolledOut(8) {
+		t.Errorf("expected revision 8 not to be rolled out to all nodes")
+	}
+	if !status.RolledOut(7) {
+		t.Errorf("expected revision 7 to be rolled out to all nodes")
+	}
+}
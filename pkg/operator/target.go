@@ -0,0 +1,90 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	DeploymentKind  = "Deployment"
+	DaemonSetKind   = "DaemonSet"
+	StatefulSetKind = "StatefulSet"
+)
+
+// targetKinds maps the resource names accepted in the target to the workload kind.
+var targetKinds = map[string]string{
+	"deployment":   DeploymentKind,
+	"deployments":  DeploymentKind,
+	"deploy":       DeploymentKind,
+	"daemonset":    DaemonSetKind,
+	"daemonsets":   DaemonSetKind,
+	"ds":           DaemonSetKind,
+	"statefulset":  StatefulSetKind,
+	"statefulsets": StatefulSetKind,
+	"sts":          StatefulSetKind,
+}
+
+// Target is the operator workload a command works with. It is either the workload of a cluster operator, found from the
+// cluster operator name, or a workload given directly by its kind, namespace and name, which works without the cluster
+// version operator (eg. on kind clusters).
+type Target struct {
+	// ClusterOperator is the cluster operator name, empty when the workload is given directly.
+	ClusterOperator string
+
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// ParseTarget parses "name", "clusteroperator/name", "deployment/namespace/name", "daemonset/namespace/name" and
+// "statefulset/namespace/name".
+func ParseTarget(arg string) (*Target, error) {
+	parts := strings.Split(arg, "/")
+	switch {
+	case len(parts) == 1 && len(parts[0]) > 0:
+		return &Target{ClusterOperator: parts[0]}, nil
+	case len(parts) == 2 && (parts[0] == "clusteroperator" || parts[0] == "clusteroperators" || parts[0] == "co") && len(parts[1]) > 0:
+		return &Target{ClusterOperator: parts[1]}, nil
+	case len(parts) == 3:
+		kind, err := ParseWorkloadKind(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("unsupported target %q: %v", arg, err)
+		}
+		if len(parts[1]) == 0 || len(parts[2]) == 0 {
+			return nil, fmt.Errorf("invalid target %q, expected %s/namespace/name", arg, strings.ToLower(kind))
+		}
+		return &Target{Kind: kind, Namespace: parts[1], Name: parts[2]}, nil
+	default:
+		return nil, fmt.Errorf("invalid target %q, expected clusteroperator/name or deployment|daemonset|statefulset/namespace/name", arg)
+	}
+}
+
+// ParseWorkloadKind returns the workload kind for the resource name (eg. "deploy" or "daemonsets").
+func ParseWorkloadKind(resource string) (string, error) {
+	kind, ok := targetKinds[strings.ToLower(resource)]
+	if !ok {
+		return "", fmt.Errorf("only deployment, daemonset and statefulset workloads are supported")
+	}
+	return kind, nil
+}
+
+// IsClusterOperator returns true when the target was given by the cluster operator name.
+func (t *Target) IsClusterOperator() bool {
+	return len(t.ClusterOperator) > 0
+}
+
+// ID identifies the target in the operator lock, the audit log and the local state. It is the cluster operator name, or
+// "kind.namespace.name" for workloads given directly, as the lock lease name can not contain slashes.
+func (t *Target) ID() string {
+	if t.IsClusterOperator() {
+		return t.ClusterOperator
+	}
+	return fmt.Sprintf("%s.%s.%s", strings.ToLower(t.Kind), t.Namespace, t.Name)
+}
+
+func (t *Target) String() string {
+	if t.IsClusterOperator() {
+		return "clusteroperator/" + t.ClusterOperator
+	}
+	return fmt.Sprintf("%s/%s/%s", strings.ToLower(t.Kind), t.Namespace, t.Name)
+}
@@ -0,0 +1,57 @@
+package operator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		arg         string
+		expected    *Target
+		expectedID  string
+		expectError bool
+	}{
+		{arg: "kube-apiserver", expected: &Target{ClusterOperator: "kube-apiserver"}, expectedID: "kube-apiserver"},
+		{arg: "clusteroperator/kube-apiserver", expected: &Target{ClusterOperator: "kube-apiserver"}, expectedID: "kube-apiserver"},
+		{
+			arg:        "deployment/foo-system/foo-operator",
+			expected:   &Target{Kind: DeploymentKind, Namespace: "foo-system", Name: "foo-operator"},
+			expectedID: "deployment.foo-system.foo-operator",
+		},
+		{
+			arg:        "ds/openshift-dns/dns-default",
+			expected:   &Target{Kind: DaemonSetKind, Namespace: "openshift-dns", Name: "dns-default"},
+			expectedID: "daemonset.openshift-dns.dns-default",
+		},
+		{
+			arg:        "statefulset/foo/bar",
+			expected:   &Target{Kind: StatefulSetKind, Namespace: "foo", Name: "bar"},
+			expectedID: "statefulset.foo.bar",
+		},
+		{arg: "pod/foo/bar", expectError: true},
+		{arg: "deployment/foo", expectError: true},
+		{arg: "deployment//bar", expectError: true},
+		{arg: "", expectError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.arg, func(t *testing.T) {
+			target, err := ParseTarget(test.arg)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected error, got %#v", target)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(target, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, target)
+			}
+			if target.ID() != test.expectedID {
+				t.Errorf("expected ID %q, got %q", test.expectedID, target.ID())
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// GetPodTemplate returns the pod template of the deployment, daemon set or stateful set.
+func GetPodTemplate(client kubernetes.Interface, kind, namespace, name string) (*corev1.PodTemplateSpec, error) {
+	var template *corev1.PodTemplateSpec
+	switch kind {
+	case DaemonSetKind:
+		daemonSet, err := client.AppsV1().DaemonSets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get daemonset %s/%s: %v", namespace, name, err)
+		}
+		template = &daemonSet.Spec.Template
+	case StatefulSetKind:
+		statefulSet, err := client.AppsV1().StatefulSets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get statefulset %s/%s: %v", namespace, name, err)
+		}
+		template = &statefulSet.Spec.Template
+	default:
+		deployment, err := client.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get deployment %s/%s: %v", namespace, name, err)
+		}
+		template = &deployment.Spec.Template
+	}
+	return template, nil
+}
+
+// UpdatePodTemplate changes the pod template of the deployment, daemon set or stateful set. It returns the pod template
+// before the change, so the change can be reverted.
+func UpdatePodTemplate(client kubernetes.Interface, kind, namespace, name string, mutate func(template *corev1.PodTemplateSpec) error) (*corev1.PodTemplateSpec, error) {
+	var previous *corev1.PodTemplateSpec
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		switch kind {
+		case DaemonSetKind:
+			daemonSet, err := client.AppsV1().DaemonSets(namespace).Get(name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			previous = daemonSet.Spec.Template.DeepCopy()
+			if err := mutate(&daemonSet.Spec.Template); err != nil {
+				return err
+			}
+			_, err = client.AppsV1().DaemonSets(namespace).Update(daemonSet)
+			return err
+		case StatefulSetKind:
+			statefulSet, err := client.AppsV1().StatefulSets(namespace).Get(name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			previous = statefulSet.Spec.Template.DeepCopy()
+			if err := mutate(&statefulSet.Spec.Template); err != nil {
+				return err
+			}
+			_, err = client.AppsV1().StatefulSets(namespace).Update(statefulSet)
+			return err
+		default:
+			deployment, err := client.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			previous = deployment.Spec.Template.DeepCopy()
+			if err := mutate(&deployment.Spec.Template); err != nil {
+				return err
+			}
+			_, err = client.AppsV1().Deployments(namespace).Update(deployment)
+			return err
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// WorkloadOverride returns the component override for the given deployment, daemon set or stateful set.
+func WorkloadOverride(kind, namespace, name string) ComponentOverride {
+	return ComponentOverride{Kind: kind, Group: "apps/v1", Namespace: namespace, Name: name}
+}
+
+// WorkloadString returns the workload in the "kind namespace/name" form used in the messages.
+func WorkloadString(kind, namespace, name string) string {
+	return fmt.Sprintf("%s %s/%s", strings.ToLower(kind), namespace, name)
+}
@@ -0,0 +1,150 @@
+package portforward
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// Endpoint is a HTTP(S) port of an operator pod.
+type Endpoint struct {
+	Namespace string
+	Pod       string
+	Container string
+	Port      int32
+	Scheme    string
+}
+
+// FindOperatorEndpoint returns the metrics endpoint of a running operator pod.
+// OpenShift operators serve metrics and pprof on the secure port (usually 8443, often behind kube-rbac-proxy).
+func FindOperatorEndpoint(client kubernetes.Interface, deployment *appsv1.Deployment) (*Endpoint, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := client.CoreV1().Pods(deployment.Namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list pods of deployment %s/%s: %v", deployment.Namespace, deployment.Name, err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		endpoint, ok := metricsEndpoint(pod.Spec.Containers)
+		if !ok {
+			return nil, fmt.Errorf("pod %s/%s does not expose any container port", pod.Namespace, pod.Name)
+		}
+		endpoint.Namespace, endpoint.Pod = pod.Namespace, pod.Name
+		return endpoint, nil
+	}
+	return nil, fmt.Errorf("no running pod found for deployment %s/%s", deployment.Namespace, deployment.Name)
+}
+
+// metricsEndpoint picks the container port serving metrics. Ports named https or metrics and the port 8443 are preferred,
+// otherwise the first declared port is used.
+func metricsEndpoint(containers []corev1.Container) (*Endpoint, bool) {
+	var first *Endpoint
+	for _, container := range containers {
+		for _, port := range container.Ports {
+			endpoint := &Endpoint{Container: container.Name, Port: port.ContainerPort, Scheme: "https"}
+			if port.Name == "http" {
+				endpoint.Scheme = "http"
+			}
+			if port.Name == "https" || port.Name == "metrics" || port.ContainerPort == 8443 {
+				return endpoint, true
+			}
+			if first == nil {
+				first = endpoint
+			}
+		}
+	}
+	return first, first != nil
+}
+
+// Forward opens a port-forward to the endpoint on a random local port and returns the local port.
+// The port-forward is closed when the stop channel is closed.
+func Forward(restConfig *rest.Config, client kubernetes.Interface, endpoint *Endpoint, stopCh <-chan struct{}) (uint16, error) {
+	transport, upgrader, err := spdy.RoundTripperFor(restConfig)
+	if err != nil {
+		return 0, err
+	}
+	url := client.CoreV1().RESTClient().Post().Resource("pods").Namespace(endpoint.Namespace).Name(endpoint.Pod).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", url)
+
+	readyCh := make(chan struct{})
+	forwarder, err := portforward.New(dialer, []string{fmt.Sprintf("0:%d", endpoint.Port)}, stopCh, readyCh, ioutil.Discard, ioutil.Discard)
+	if err != nil {
+		return 0, err
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- forwarder.ForwardPorts()
+	}()
+
+	select {
+	case <-readyCh:
+	case err := <-errCh:
+		return 0, fmt.Errorf("unable to port-forward to pod %s/%s: %v", endpoint.Namespace, endpoint.Pod, err)
+	}
+	ports, err := forwarder.GetPorts()
+	if err != nil {
+		return 0, err
+	}
+	return ports[0].Local, nil
+}
+
+// BearerToken returns the token the user is authenticated with. The operator endpoints use delegated authentication,
+// so the client certificate in the kubeconfig can not be used to access them.
+func BearerToken(restConfig *rest.Config) (string, error) {
+	if len(restConfig.BearerToken) > 0 {
+		return restConfig.BearerToken, nil
+	}
+	if len(restConfig.BearerTokenFile) > 0 {
+		token, err := ioutil.ReadFile(restConfig.BearerTokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(token)), nil
+	}
+	return "", fmt.Errorf("bearer token is required to access the operator endpoint, use 'oc login' with a token")
+}
+
+// Get fetches the path from the forwarded local port. The serving certificate is issued for the service name,
+// so it can not be verified via the port-forward.
+func Get(scheme string, localPort uint16, path, token string, timeout time.Duration) ([]byte, error) {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s://localhost:%d%s", scheme, localPort, path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
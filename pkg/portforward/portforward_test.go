@@ -0,0 +1,51 @@
+package portforward
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_metricsEndpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers []corev1.Container
+		expectPort int32
+		expectOK   bool
+	}{
+		{
+			name: "no ports",
+			containers: []corev1.Container{
+				{Name: "operator"},
+			},
+		},
+		{
+			name: "first port",
+			containers: []corev1.Container{
+				{Name: "operator", Ports: []corev1.ContainerPort{{ContainerPort: 9090}}},
+			},
+			expectPort: 9090,
+			expectOK:   true,
+		},
+		{
+			name: "kube-rbac-proxy port",
+			containers: []corev1.Container{
+				{Name: "operator", Ports: []corev1.ContainerPort{{ContainerPort: 9090}}},
+				{Name: "kube-rbac-proxy", Ports: []corev1.ContainerPort{{Name: "https", ContainerPort: 9443}}},
+			},
+			expectPort: 9443,
+			expectOK:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			endpoint, ok := metricsEndpoint(test.containers)
+			if ok != test.expectOK {
+				t.Fatalf("expected %v, got %v", test.expectOK, ok)
+			}
+			if ok && endpoint.Port != test.expectPort {
+				t.Errorf("expected port %d, got %d", test.expectPort, endpoint.Port)
+			}
+		})
+	}
+}
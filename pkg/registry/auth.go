@@ -0,0 +1,119 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/client-go/util/homedir"
+)
+
+// DockerConfig is the content of a .dockerconfigjson pull secret or a docker/podman auth file.
+type DockerConfig struct {
+	Auths map[string]AuthEntry `json:"auths"`
+}
+
+// AuthEntry are the credentials for a single registry.
+type AuthEntry struct {
+	Auth     string `json:"auth,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// ParseDockerConfig parses both the current ({"auths": {...}}) and the legacy .dockercfg format.
+func ParseDockerConfig(data []byte) (*DockerConfig, error) {
+	config := &DockerConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid docker config: %v", err)
+	}
+	if len(config.Auths) > 0 {
+		return config, nil
+	}
+	legacy := map[string]AuthEntry{}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, fmt.Errorf("invalid docker config: %v", err)
+	}
+	config.Auths = legacy
+	return config, nil
+}
+
+// credentials returns the username and password for the registry.
+func (c *DockerConfig) credentials(registry string) (string, string, bool) {
+	if c == nil {
+		return "", "", false
+	}
+	keys := []string{registry, "https://" + registry, "http://" + registry}
+	if registry == dockerHub {
+		keys = append(keys, "https://index.docker.io/v1/", "index.docker.io", "registry-1.docker.io")
+	}
+	for _, key := range keys {
+		entry, ok := c.Auths[key]
+		if !ok {
+			continue
+		}
+		if len(entry.Username) > 0 {
+			return entry.Username, entry.Password, true
+		}
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			continue
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		return parts[0], parts[1], true
+	}
+	return "", "", false
+}
+
+// LocalAuthFile returns the docker or podman auth file of the current user.
+func LocalAuthFile() (string, error) {
+	candidates := []string{os.Getenv("REGISTRY_AUTH_FILE")}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); len(runtimeDir) > 0 {
+		candidates = append(candidates, filepath.Join(runtimeDir, "containers", "auth.json"))
+	}
+	candidates = append(candidates,
+		filepath.Join(homedir.HomeDir(), ".config", "containers", "auth.json"),
+		filepath.Join(homedir.HomeDir(), ".docker", "config.json"),
+	)
+	for _, candidate := range candidates {
+		if len(candidate) == 0 {
+			continue
+		}
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no docker or podman auth file found, use 'podman login' or 'docker login' first")
+}
+
+// Filter returns the config with credentials for the given registries only.
+func (c *DockerConfig) Filter(registries []string) *DockerConfig {
+	filtered := &DockerConfig{Auths: map[string]AuthEntry{}}
+	for _, registry := range registries {
+		username, password, ok := c.credentials(registry)
+		if !ok {
+			continue
+		}
+		filtered.Auths[registry] = AuthEntry{Auth: base64.StdEncoding.EncodeToString([]byte(username + ":" + password))}
+	}
+	return filtered
+}
+
+// Merge returns the config with credentials from both configs, the other config wins for the same registry.
+func (c *DockerConfig) Merge(other *DockerConfig) *DockerConfig {
+	merged := &DockerConfig{Auths: map[string]AuthEntry{}}
+	for _, config := range []*DockerConfig{c, other} {
+		if config == nil {
+			continue
+		}
+		for registry, entry := range config.Auths {
+			merged.Auths[registry] = entry
+		}
+	}
+	return merged
+}
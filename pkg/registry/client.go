@@ -0,0 +1,220 @@
+package registry
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// manifestMediaTypes are the manifest and manifest list formats the client accepts.
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v1+prettyjws",
+}
+
+// challengeParam matches the parameters of the WWW-Authenticate header, eg. realm="https://auth.docker.io/token".
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Client reads image manifests using the registry v2 API.
+type Client struct {
+	config   *DockerConfig
+	insecure bool
+	client   *http.Client
+}
+
+// NewClient returns a client authenticating with the credentials from the docker config. Insecure registries are accessed
+// without verifying the certificate and with a fallback to plain HTTP, which is useful for local test registries.
+func NewClient(config *DockerConfig, insecure bool) *Client {
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &Client{
+		config:   config,
+		insecure: insecure,
+		client:   &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}
+}
+
+type manifest struct {
+	Manifests []struct {
+		Platform struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+		} `json:"platform"`
+	} `json:"manifests"`
+	Config struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	// Architecture is only set in the schema 1 manifests
+	Architecture string `json:"architecture"`
+}
+
+// Architectures verifies the image exists and is readable with the credentials and returns the architectures it is
+// available for.
+func (c *Client) Architectures(ref *Reference) ([]string, error) {
+	data, err := c.get(ref, "manifests/"+ref.manifestReference(), manifestMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+	m := &manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid manifest of image %s: %v", ref, err)
+	}
+
+	switch {
+	case len(m.Manifests) > 0:
+		var architectures []string
+		for _, platform := range m.Manifests {
+			if len(platform.Platform.OS) > 0 && platform.Platform.OS != "linux" {
+				continue
+			}
+			architectures = append(architectures, platform.Platform.Architecture)
+		}
+		return architectures, nil
+	case len(m.Config.Digest) > 0:
+		data, err := c.get(ref, "blobs/"+m.Config.Digest, nil)
+		if err != nil {
+			return nil, err
+		}
+		config := &manifest{}
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("invalid config of image %s: %v", ref, err)
+		}
+		return []string{config.Architecture}, nil
+	case len(m.Architecture) > 0:
+		return []string{m.Architecture}, nil
+	default:
+		return nil, fmt.Errorf("unable to determine the architecture of image %s", ref)
+	}
+}
+
+// get reads the path in the image repository, authenticating when the registry asks for it.
+func (c *Client) get(ref *Reference, path string, accept []string) ([]byte, error) {
+	scheme := "https"
+	resp, err := c.do(scheme, ref, path, accept, "")
+	if err != nil && c.insecure {
+		scheme = "http"
+		resp, err = c.do(scheme, ref, path, accept, "")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach registry %s: %v", ref.Registry, err)
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		authorization, err := c.authorize(ref, challenge)
+		if err != nil {
+			return nil, err
+		}
+		if resp, err = c.do(scheme, ref, path, accept, authorization); err != nil {
+			return nil, fmt.Errorf("unable to reach registry %s: %v", ref.Registry, err)
+		}
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("image %s not found", ref)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		if _, _, ok := c.config.credentials(ref.Registry); !ok {
+			return nil, fmt.Errorf("access to image %s denied, the pull secret has no credentials for %s", ref, ref.Registry)
+		}
+		return nil, fmt.Errorf("access to image %s denied, check the credentials for %s in the pull secret", ref, ref.Registry)
+	default:
+		return nil, fmt.Errorf("unable to get image %s: %s: %s", ref, resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+func (c *Client) do(scheme string, ref *Reference, path string, accept []string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s://%s/v2/%s/%s", scheme, ref.apiHost(), ref.Repository, path), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+	return c.client.Do(req)
+}
+
+// authorize returns the Authorization header answering the registry challenge.
+func (c *Client) authorize(ref *Reference, challenge string) (string, error) {
+	username, password, hasCredentials := c.config.credentials(ref.Registry)
+	params := map[string]string{}
+	for _, match := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+
+	switch {
+	case strings.HasPrefix(strings.ToLower(challenge), "basic"):
+		if !hasCredentials {
+			return "", fmt.Errorf("access to image %s denied, the pull secret has no credentials for %s", ref, ref.Registry)
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(username, password)
+		return req.Header.Get("Authorization"), nil
+	case strings.HasPrefix(strings.ToLower(challenge), "bearer"):
+		realm, err := url.Parse(params["realm"])
+		if err != nil || len(params["realm"]) == 0 {
+			return "", fmt.Errorf("invalid authentication challenge from registry %s: %q", ref.Registry, challenge)
+		}
+		query := realm.Query()
+		if len(params["service"]) > 0 {
+			query.Set("service", params["service"])
+		}
+		scope := params["scope"]
+		if len(scope) == 0 {
+			scope = "repository:" + ref.Repository + ":pull"
+		}
+		query.Set("scope", scope)
+		realm.RawQuery = query.Encode()
+
+		req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if hasCredentials {
+			req.SetBasicAuth(username, password)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("unable to get token for registry %s: %v", ref.Registry, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("access to image %s denied, registry %s token request returned %s", ref, ref.Registry, resp.Status)
+		}
+		token := struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}{}
+		if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+			return "", fmt.Errorf("invalid token from registry %s: %v", ref.Registry, err)
+		}
+		if len(token.Token) == 0 {
+			token.Token = token.AccessToken
+		}
+		return "Bearer " + token.Token, nil
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge from registry %s: %q", ref.Registry, challenge)
+	}
+}
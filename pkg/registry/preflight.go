@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// globalPullSecretNamespace and globalPullSecretName identify the pull secret used by all cluster nodes.
+	globalPullSecretNamespace = "openshift-config"
+	globalPullSecretName      = "pull-secret"
+
+	// the control-plane nodes have the master role label, the control-plane role label, or both
+	masterNodeLabel       = "node-role.kubernetes.io/master"
+	controlPlaneNodeLabel = "node-role.kubernetes.io/control-plane"
+)
+
+// GlobalPullSecret returns the cluster global pull secret, or nil when the user can not read it.
+func GlobalPullSecret(client kubernetes.Interface) (*DockerConfig, error) {
+	secret, err := client.CoreV1().Secrets(globalPullSecretNamespace).Get(globalPullSecretName, metav1.GetOptions{})
+	if errors.IsNotFound(err) || errors.IsForbidden(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseDockerConfig(secret.Data[corev1.DockerConfigJsonKey])
+}
+
+// NodeArchitectures returns the architectures of the nodes the workload with the node selector runs on. The operators
+// of the cluster version operator run on the control-plane nodes, the operators installed by OLM usually have no node
+// selector and run on any node. No architectures are returned when the cluster has no matching nodes.
+func NodeArchitectures(client kubernetes.Interface, nodeSelector map[string]string) ([]string, error) {
+	for _, selector := range nodeSelectors(nodeSelector) {
+		nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		if len(nodes.Items) > 0 {
+			return nodeArchitectures(nodes.Items), nil
+		}
+	}
+	return nil, nil
+}
+
+// nodeSelectors returns the label selectors to find the nodes with, in order. When no node has the control-plane role
+// of the node selector, the other control-plane role is tried, then all nodes.
+func nodeSelectors(nodeSelector map[string]string) []string {
+	if len(nodeSelector) == 0 {
+		return []string{""}
+	}
+	selectors := []string{labels.SelectorFromSet(nodeSelector).String()}
+	for _, roles := range [][2]string{{masterNodeLabel, controlPlaneNodeLabel}, {controlPlaneNodeLabel, masterNodeLabel}} {
+		role, other := roles[0], roles[1]
+		value, ok := nodeSelector[role]
+		if !ok {
+			continue
+		}
+		otherSelector := labels.Set{}
+		for k, v := range nodeSelector {
+			otherSelector[k] = v
+		}
+		delete(otherSelector, role)
+		otherSelector[other] = value
+		selectors = append(selectors, labels.SelectorFromSet(otherSelector).String())
+	}
+	return append(selectors, "")
+}
+
+func nodeArchitectures(nodes []corev1.Node) []string {
+	seen := map[string]bool{}
+	var architectures []string
+	for _, node := range nodes {
+		architecture := node.Status.NodeInfo.Architecture
+		if len(architecture) > 0 && !seen[architecture] {
+			seen[architecture] = true
+			architectures = append(architectures, architecture)
+		}
+	}
+	sort.Strings(architectures)
+	return architectures
+}
+
+// missingArchitectures returns the required architectures the image is not available for.
+func missingArchitectures(required, available []string) []string {
+	var missing []string
+	for _, r := range required {
+		found := false
+		for _, a := range available {
+			if a == r {
+				found = true
+			}
+		}
+		if !found {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+// Preflight checks the image exists, can be pulled with the credentials and is available for all architectures.
+func (c *Client) Preflight(image string, architectures []string) error {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return err
+	}
+	available, err := c.Architectures(ref)
+	if err != nil {
+		return err
+	}
+	if missing := missingArchitectures(architectures, available); len(missing) > 0 {
+		return fmt.Errorf("image %s is not available for %s architecture of the nodes (image architectures: %s)",
+			image, strings.Join(missing, ", "), strings.Join(available, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dockerHub is the registry used for images without registry host.
+const dockerHub = "docker.io"
+
+// Reference is a parsed image pull spec.
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses the image pull spec, defaulting the registry to docker.io and the tag to latest.
+func ParseReference(image string) (*Reference, error) {
+	if len(image) == 0 || strings.ContainsAny(image, " \t") {
+		return nil, fmt.Errorf("invalid image reference %q", image)
+	}
+	ref := &Reference{}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+		if !strings.Contains(ref.Digest, ":") {
+			return nil, fmt.Errorf("invalid digest in image reference %q", image)
+		}
+	}
+	// the tag separator is the last colon after the last slash, the colon before it is the registry port
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+	}
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Registry, ref.Repository = parts[0], parts[1]
+	} else {
+		ref.Registry, ref.Repository = dockerHub, name
+		if !strings.Contains(name, "/") {
+			ref.Repository = "library/" + name
+		}
+	}
+	if len(ref.Repository) == 0 || strings.HasSuffix(ref.Repository, "/") {
+		return nil, fmt.Errorf("invalid repository in image reference %q", image)
+	}
+	if len(ref.Tag) == 0 && len(ref.Digest) == 0 {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+func (r *Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if len(r.Tag) > 0 {
+		s += ":" + r.Tag
+	}
+	if len(r.Digest) > 0 {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// manifestReference returns the digest or the tag used to get the image manifest.
+func (r *Reference) manifestReference() string {
+	if len(r.Digest) > 0 {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// apiHost returns the host serving the registry API.
+func (r *Reference) apiHost() string {
+	if r.Registry == dockerHub {
+		return "registry-1.docker.io"
+	}
+	return r.Registry
+}
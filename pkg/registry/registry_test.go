@@ -0,0 +1,159 @@
+package registry
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected Reference
+	}{
+		{image: "busybox", expected: Reference{Registry: "docker.io", Repository: "library/busybox", Tag: "latest"}},
+		{image: "foo/bar:dev", expected: Reference{Registry: "docker.io", Repository: "foo/bar", Tag: "dev"}},
+		{image: "quay.io/foo/bar@sha256:abc", expected: Reference{Registry: "quay.io", Repository: "foo/bar", Digest: "sha256:abc"}},
+		{image: "localhost:5000/foo:v1", expected: Reference{Registry: "localhost:5000", Repository: "foo", Tag: "v1"}},
+		{image: "registry.svc:5000/ns/foo", expected: Reference{Registry: "registry.svc:5000", Repository: "ns/foo", Tag: "latest"}},
+	}
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			ref, err := ParseReference(test.image)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*ref, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, *ref)
+			}
+		})
+	}
+	if _, err := ParseReference("quay.io/foo bar"); err == nil {
+		t.Errorf("expected error for invalid reference")
+	}
+}
+
+// testRegistry serves the "private/operator" repository with bearer token authentication.
+func testRegistry(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			user, password, ok := r.BasicAuth()
+			if !ok || user != "user" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token":"valid"}`)
+		case r.Header.Get("Authorization") != "Bearer valid":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v2/private/operator/manifests/multi":
+			fmt.Fprint(w, `{"manifests":[{"platform":{"architecture":"amd64","os":"linux"}},{"platform":{"architecture":"arm64","os":"linux"}}]}`)
+		case r.URL.Path == "/v2/private/operator/manifests/single":
+			fmt.Fprint(w, `{"config":{"digest":"sha256:config"}}`)
+		case r.URL.Path == "/v2/private/operator/blobs/sha256:config":
+			fmt.Fprint(w, `{"architecture":"arm64"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+func TestPreflight(t *testing.T) {
+	server := testRegistry(t)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	auth := base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	config, err := ParseDockerConfig([]byte(fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, host, auth)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		config        *DockerConfig
+		image         string
+		architectures []string
+		expectErr     string
+	}{
+		{name: "manifest list", config: config, image: host + "/private/operator:multi", architectures: []string{"amd64"}},
+		{name: "single manifest", config: config, image: host + "/private/operator:single", architectures: []string{"arm64"}},
+		{name: "wrong architecture", config: config, image: host + "/private/operator:single", architectures: []string{"amd64"}, expectErr: "not available for amd64"},
+		{name: "typo", config: config, image: host + "/private/operatr:multi", expectErr: "not found"},
+		{name: "no credentials", image: host + "/private/operator:multi", expectErr: "denied"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := NewClient(test.config, true).Preflight(test.image, test.architectures)
+			switch {
+			case len(test.expectErr) == 0 && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case len(test.expectErr) > 0 && (err == nil || !strings.Contains(err.Error(), test.expectErr)):
+				t.Errorf("expected error containing %q, got %v", test.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestFilterMerge(t *testing.T) {
+	basic := func(credentials string) string { return base64.StdEncoding.EncodeToString([]byte(credentials)) }
+	local := &DockerConfig{Auths: map[string]AuthEntry{
+		"quay.io":                     {Auth: basic("me:secret")},
+		"https://index.docker.io/v1/": {Username: "hub", Password: "pass"},
+		"registry.example.com":        {Auth: basic("other:secret")},
+	}}
+
+	filtered := local.Filter([]string{"quay.io", "docker.io", "missing.io"})
+	expected := map[string]AuthEntry{
+		"quay.io":   {Auth: basic("me:secret")},
+		"docker.io": {Auth: basic("hub:pass")},
+	}
+	if !reflect.DeepEqual(filtered.Auths, expected) {
+		t.Errorf("expected %#v, got %#v", expected, filtered.Auths)
+	}
+
+	global := &DockerConfig{Auths: map[string]AuthEntry{
+		"quay.io":            {Auth: basic("cluster:secret")},
+		"registry.redhat.io": {Auth: basic("cluster:secret")},
+	}}
+	merged := global.Merge(filtered)
+	if username, _, _ := merged.credentials("quay.io"); username != "me" {
+		t.Errorf("expected quay.io credentials from the override config, got %q", username)
+	}
+	if _, _, ok := merged.credentials("registry.redhat.io"); !ok {
+		t.Errorf("expected registry.redhat.io credentials from the global pull secret")
+	}
+	var empty *DockerConfig
+	if merged := empty.Merge(filtered); len(merged.Auths) != 2 {
+		t.Errorf("expected 2 registries, got %d", len(merged.Auths))
+	}
+}
+
+func TestNodeSelectors(t *testing.T) {
+	tests := []struct {
+		name         string
+		nodeSelector map[string]string
+		expected     []string
+	}{
+		{name: "olm operator", expected: []string{""}},
+		{name: "master", nodeSelector: map[string]string{"node-role.kubernetes.io/master": ""},
+			expected: []string{"node-role.kubernetes.io/master=", "node-role.kubernetes.io/control-plane=", ""}},
+		{name: "control-plane", nodeSelector: map[string]string{"node-role.kubernetes.io/control-plane": "", "kubernetes.io/os": "linux"},
+			expected: []string{"kubernetes.io/os=linux,node-role.kubernetes.io/control-plane=", "kubernetes.io/os=linux,node-role.kubernetes.io/master=", ""}},
+		{name: "infra", nodeSelector: map[string]string{"node-role.kubernetes.io/infra": ""},
+			expected: []string{"node-role.kubernetes.io/infra=", ""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if selectors := nodeSelectors(test.nodeSelector); !reflect.DeepEqual(selectors, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, selectors)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package revert
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/manifests"
+	"github.com/mfojtik/operator-dev-plugin/pkg/olm"
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+)
+
+// Override reverts the override of the operator workload, it is used by 'override --managed' and 'reap'. The applied
+// manifests and the ClusterServiceVersion are restored from the override state stored in the cluster, the pull secret
+// is removed and the cluster version operator (when the cluster has one) manages the workload again. Every reverted
+// object is reported with printf. The operator lock is not released.
+func Override(dynamicClient dynamic.Interface, kubeClient kubernetes.Interface, operatorID, kind, namespace, name string, printf func(format string, args ...interface{})) error {
+	clusterVersion, err := operator.HasClusterVersion(dynamicClient)
+	if err != nil {
+		return err
+	}
+	if err := revertManifests(dynamicClient, kubeClient, operatorID, clusterVersion, printf); err != nil {
+		return err
+	}
+	if err := revertCSV(dynamicClient, kubeClient, operatorID, printf); err != nil {
+		return err
+	}
+	removed, err := operator.RemovePullSecret(kubeClient, kind, namespace, name)
+	if err != nil {
+		return err
+	}
+	if removed {
+		printf("-> Pull secret %s/%s removed\n", namespace, operator.PullSecretName)
+	}
+	if !clusterVersion {
+		return nil
+	}
+	// the workloads of operators installed by OLM were never unmanaged
+	component := operator.WorkloadOverride(kind, namespace, name)
+	unmanaged, err := operator.IsUnmanaged(dynamicClient, component)
+	if err != nil || !unmanaged {
+		return err
+	}
+	return operator.SetUnmanaged(dynamicClient, component, false)
+}
+
+// revertManifests puts back the objects changed by the manifests applied with the override and lets the cluster version
+// operator manage them again.
+func revertManifests(dynamicClient dynamic.Interface, kubeClient kubernetes.Interface, operatorID string, clusterVersion bool, printf func(string, ...interface{})) error {
+	state, err := manifests.LoadState(kubeClient, operatorID)
+	if err != nil || len(state) == 0 {
+		return err
+	}
+	var components []operator.ComponentOverride
+	for i := len(state) - 1; i >= 0; i-- {
+		if err := manifests.Revert(dynamicClient, state[i]); err != nil {
+			return fmt.Errorf("unable to revert %s: %v", state[i], err)
+		}
+		components = append(components, state[i].Override)
+		printf("-> Reverted %s\n", state[i])
+	}
+	if clusterVersion {
+		if err := operator.SetUnmanagedComponents(dynamicClient, false, components...); err != nil {
+			return err
+		}
+	}
+	return manifests.RemoveState(kubeClient, operatorID)
+}
+
+// revertCSV restores the ClusterServiceVersion fields changed by the override, OLM then rolls out the original deployment.
+func revertCSV(dynamicClient dynamic.Interface, kubeClient kubernetes.Interface, operatorID string, printf func(string, ...interface{})) error {
+	state, err := olm.LoadState(kubeClient, operatorID)
+	if err != nil || state == nil {
+		return err
+	}
+	if err := olm.Restore(dynamicClient, state); err != nil {
+		return fmt.Errorf("unable to revert %s: %v", state, err)
+	}
+	printf("-> Reverted %s\n", state)
+	return olm.RemoveState(kubeClient, operatorID)
+}
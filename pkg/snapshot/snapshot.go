@@ -0,0 +1,228 @@
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+)
+
+var (
+	deploymentsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	replicaSetsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}
+	podsGVR        = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	eventsGVR      = schema.GroupVersionResource{Version: "v1", Resource: "events"}
+)
+
+// Snapshot captures the state of a single operator into a directory using the must-gather layout:
+//
+//	cluster-scoped-resources/<group>/<resource>/<name>.yaml
+//	namespaces/<namespace>/<group>/<resource>/<name>.yaml
+//
+// Resources from the core group are stored under the "core" group directory.
+type Snapshot struct {
+	DynamicClient dynamic.Interface
+	Mapper        meta.RESTMapper
+
+	OperatorName        string
+	DeploymentNamespace string
+	DeploymentName      string
+}
+
+// Capture writes the current state of the operator into the given directory, replacing its previous content.
+func (s *Snapshot) Capture(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	clusterOperator, err := s.DynamicClient.Resource(operator.ClusterOperatorGVR).Get(s.OperatorName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get clusteroperator/%s: %v", s.OperatorName, err)
+	}
+	if err := writeObject(dir, operator.ClusterOperatorGVR, clusterOperator); err != nil {
+		return err
+	}
+
+	if err := s.captureObject(dir, operator.ClusterVersionGVR, "", "version"); err != nil {
+		return err
+	}
+
+	deployment, err := s.DynamicClient.Resource(deploymentsGVR).Namespace(s.DeploymentNamespace).Get(s.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get deployment %s/%s: %v", s.DeploymentNamespace, s.DeploymentName, err)
+	}
+	if err := writeObject(dir, deploymentsGVR, deployment); err != nil {
+		return err
+	}
+
+	// replica sets and pods are matched using the deployment selector
+	selector, _, _ := unstructured.NestedStringMap(deployment.Object, "spec", "selector", "matchLabels")
+	listOptions := metav1.ListOptions{LabelSelector: labelSelector(selector)}
+	for _, gvr := range []schema.GroupVersionResource{replicaSetsGVR, podsGVR} {
+		items, err := s.DynamicClient.Resource(gvr).Namespace(s.DeploymentNamespace).List(listOptions)
+		if err != nil {
+			return fmt.Errorf("unable to list %s in %s: %v", gvr.Resource, s.DeploymentNamespace, err)
+		}
+		for i := range items.Items {
+			if err := writeObject(dir, gvr, &items.Items[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, related := range operator.GetRelatedObjects(clusterOperator) {
+		gvr, err := related.GroupVersionResource(s.Mapper)
+		if err != nil {
+			// the related object type might not be served by this cluster
+			continue
+		}
+		if err := s.captureObject(dir, gvr, related.Namespace, related.Name); err != nil {
+			return err
+		}
+	}
+
+	events, err := s.DynamicClient.Resource(eventsGVR).Namespace(s.DeploymentNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to list events in %s: %v", s.DeploymentNamespace, err)
+	}
+	sort.Slice(events.Items, func(i, j int) bool {
+		return eventTimestamp(&events.Items[i]) < eventTimestamp(&events.Items[j])
+	})
+	return writeYAML(filepath.Join(dir, "namespaces", s.DeploymentNamespace, "core", "events.yaml"), events)
+}
+
+func (s *Snapshot) captureObject(dir string, gvr schema.GroupVersionResource, namespace, name string) error {
+	obj, err := s.DynamicClient.Resource(gvr).Namespace(namespace).Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to get %s %s/%s: %v", gvr.Resource, namespace, name, err)
+	}
+	return writeObject(dir, gvr, obj)
+}
+
+// eventTimestamp returns the RFC3339 time the event was last seen, which sorts lexicographically.
+func eventTimestamp(event *unstructured.Unstructured) string {
+	if t, _, _ := unstructured.NestedString(event.Object, "lastTimestamp"); len(t) > 0 {
+		return t
+	}
+	t, _, _ := unstructured.NestedString(event.Object, "eventTime")
+	return t
+}
+
+func labelSelector(labels map[string]string) string {
+	var result []string
+	for k, v := range labels {
+		result = append(result, k+"="+v)
+	}
+	sort.Strings(result)
+	return strings.Join(result, ",")
+}
+
+// objectPath returns the must-gather path of the given object.
+func objectPath(dir string, gvr schema.GroupVersionResource, obj *unstructured.Unstructured) string {
+	group := gvr.Group
+	if len(group) == 0 {
+		group = "core"
+	}
+	if len(obj.GetNamespace()) == 0 {
+		return filepath.Join(dir, "cluster-scoped-resources", group, gvr.Resource, obj.GetName()+".yaml")
+	}
+	return filepath.Join(dir, "namespaces", obj.GetNamespace(), group, gvr.Resource, obj.GetName()+".yaml")
+}
+
+func writeObject(dir string, gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	return writeYAML(objectPath(dir, gvr, obj), obj)
+}
+
+func writeYAML(path string, obj interface{}) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// WriteSummary writes an unified diff of all files that differ between the before and after directories.
+func WriteSummary(beforeDir, afterDir string, out io.Writer) error {
+	beforeFiles, err := listFiles(beforeDir)
+	if err != nil {
+		return err
+	}
+	afterFiles, err := listFiles(afterDir)
+	if err != nil {
+		return err
+	}
+
+	paths := map[string]bool{}
+	for p := range beforeFiles {
+		paths[p] = true
+	}
+	for p := range afterFiles {
+		paths[p] = true
+	}
+	sorted := []string{}
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	for _, p := range sorted {
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(beforeFiles[p]),
+			B:        difflib.SplitLines(afterFiles[p]),
+			FromFile: filepath.Join("before", p),
+			ToFile:   filepath.Join("after", p),
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(out, diff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listFiles returns the content of all files in the directory keyed by their relative path.
+func listFiles(dir string) (map[string]string, error) {
+	result := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		result[rel] = string(data)
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,67 @@
+package snapshot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_objectPath(t *testing.T) {
+	namespaced := &unstructured.Unstructured{}
+	namespaced.SetNamespace("openshift-kube-apiserver-operator")
+	namespaced.SetName("kube-apiserver-operator")
+	clusterScoped := &unstructured.Unstructured{}
+	clusterScoped.SetName("kube-apiserver")
+
+	if got, expected := objectPath("dir", deploymentsGVR, namespaced), "dir/namespaces/openshift-kube-apiserver-operator/apps/deployments/kube-apiserver-operator.yaml"; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if got, expected := objectPath("dir", schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, clusterScoped), "dir/cluster-scoped-resources/core/namespaces/kube-apiserver.yaml"; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"before/same.yaml":    "a: 1\n",
+		"after/same.yaml":     "a: 1\n",
+		"before/changed.yaml": "image: old\n",
+		"after/changed.yaml":  "image: new\n",
+		"after/added.yaml":    "added: true\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	if err := WriteSummary(filepath.Join(dir, "before"), filepath.Join(dir, "after"), out); err != nil {
+		t.Fatal(err)
+	}
+	summary := out.String()
+	for _, expected := range []string{"-image: old", "+image: new", "+added: true"} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("expected summary to contain %q, got:\n%s", expected, summary)
+		}
+	}
+	if strings.Contains(summary, "same.yaml") {
+		t.Errorf("expected unchanged file to be omitted, got:\n%s", summary)
+	}
+}
@@ -0,0 +1,119 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/mfojtik/operator-dev-plugin/pkg/operator"
+)
+
+// configMapPrefix is the name prefix of the config maps holding the override state.
+const configMapPrefix = "override-"
+
+// ConfigMapName returns the name of the config map in the plugin namespace holding the state of the override of the
+// given operator. The state is kept in the cluster, so the override can be reverted by other users and by the reaper.
+func ConfigMapName(operatorName string) string {
+	return configMapPrefix + operatorName
+}
+
+// List returns the operators with a saved override state.
+func List(client kubernetes.Interface) ([]string, error) {
+	configMaps, err := client.CoreV1().ConfigMaps(operator.PluginNamespace).List(metav1.ListOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var operators []string
+	for _, configMap := range configMaps.Items {
+		if strings.HasPrefix(configMap.Name, configMapPrefix) {
+			operators = append(operators, strings.TrimPrefix(configMap.Name, configMapPrefix))
+		}
+	}
+	return operators, nil
+}
+
+// Load reads the state saved under the key for the operator into value. It returns false when no state was saved.
+func Load(client kubernetes.Interface, operatorName, key string, value interface{}) (bool, error) {
+	configMap, err := client.CoreV1().ConfigMaps(operator.PluginNamespace).Get(ConfigMapName(operatorName), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	data, ok := configMap.Data[key]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(data), value); err != nil {
+		return false, fmt.Errorf("unable to read %s from config map %s/%s: %v", key, operator.PluginNamespace, configMap.Name, err)
+	}
+	return true, nil
+}
+
+// Save stores the value under the key for the operator.
+func Save(client kubernetes.Interface, operatorName, key string, value interface{}) error {
+	if err := operator.EnsurePluginNamespace(client); err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	configMaps := client.CoreV1().ConfigMaps(operator.PluginNamespace)
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		configMap, err := configMaps.Get(ConfigMapName(operatorName), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = configMaps.Create(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName(operatorName), Namespace: operator.PluginNamespace},
+				Data:       map[string]string{key: string(data)},
+			})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[key] = string(data)
+		_, err = configMaps.Update(configMap)
+		return err
+	})
+}
+
+// Remove removes the state saved under the key for the operator. The config map is deleted with the last key.
+func Remove(client kubernetes.Interface, operatorName, key string) error {
+	configMaps := client.CoreV1().ConfigMaps(operator.PluginNamespace)
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		configMap, err := configMaps.Get(ConfigMapName(operatorName), metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := configMap.Data[key]; !ok {
+			return nil
+		}
+		delete(configMap.Data, key)
+		if len(configMap.Data) == 0 {
+			err = configMaps.Delete(configMap.Name, &metav1.DeleteOptions{Preconditions: &metav1.Preconditions{ResourceVersion: &configMap.ResourceVersion}})
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		_, err = configMaps.Update(configMap)
+		return err
+	})
+}
@@ -0,0 +1,111 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Step is a single change made to the cluster. Undo reverts the change, steps without Undo do not change anything.
+type Step struct {
+	Name string
+	Do   func(ctx context.Context) error
+	Undo func() error
+}
+
+// Transaction runs steps and remembers how to undo those already done.
+type Transaction struct {
+	out  io.Writer
+	done []Step
+}
+
+// New returns a transaction printing the rolled back steps to out.
+func New(out io.Writer) *Transaction {
+	return &Transaction{out: out}
+}
+
+// Run runs the step unless the context is already cancelled. The step is remembered for rollback only when it succeeds.
+func (t *Transaction) Run(ctx context.Context, step Step) error {
+	if err := ctx.Err(); err != nil {
+		return interrupted(err)
+	}
+	if err := step.Do(ctx); err != nil {
+		if ctx.Err() != nil {
+			return interrupted(ctx.Err())
+		}
+		return fmt.Errorf("%s: %v", step.Name, err)
+	}
+	if step.Undo != nil {
+		t.done = append(t.done, step)
+	}
+	return nil
+}
+
+// Rollback undoes the steps already done in reverse order and reports every step that was rolled back.
+// All steps are attempted even when some of them fail, the failures are returned as a single error.
+func (t *Transaction) Rollback() error {
+	var failed []string
+	for i := len(t.done) - 1; i >= 0; i-- {
+		step := t.done[i]
+		if err := step.Undo(); err != nil {
+			fmt.Fprintf(t.out, "-> Failed to roll back %s: %v\n", step.Name, err)
+			failed = append(failed, step.Name)
+			continue
+		}
+		fmt.Fprintf(t.out, "-> Rolled back %s\n", step.Name)
+	}
+	t.done = nil
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to roll back: %v", failed)
+	}
+	return nil
+}
+
+// Empty returns true when there is nothing to roll back.
+func (t *Transaction) Empty() bool {
+	return len(t.done) == 0
+}
+
+// Commit forgets the steps done, so they are not rolled back anymore.
+func (t *Transaction) Commit() {
+	t.done = nil
+}
+
+// Sleep waits for the given duration or until the context is cancelled.
+func Sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithSignals returns a context cancelled on SIGINT or SIGTERM. The cancel function restores the default signal handling.
+func WithSignals(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+func interrupted(err error) error {
+	if err == context.Canceled {
+		return fmt.Errorf("interrupted")
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package transaction
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTransactionRollback(t *testing.T) {
+	var undone []string
+	step := func(name string, doErr error) Step {
+		return Step{
+			Name: name,
+			Do: func(ctx context.Context) error {
+				return doErr
+			},
+			Undo: func() error {
+				undone = append(undone, name)
+				return nil
+			},
+		}
+	}
+
+	out := &bytes.Buffer{}
+	tx := New(out)
+	ctx := context.Background()
+	for _, name := range []string{"first", "second"} {
+		if err := tx.Run(ctx, step(name, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Run(ctx, step("third", fmt.Errorf("boom"))); err == nil || err.Error() != "third: boom" {
+		t.Fatalf("expected third step to fail, got %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"second", "first"}; !reflect.DeepEqual(undone, expected) {
+		t.Errorf("expected %v to be rolled back, got %v", expected, undone)
+	}
+	if expected := "-> Rolled back second\n-> Rolled back first\n"; out.String() != expected {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	if !tx.Empty() {
+		t.Errorf("expected nothing to roll back after rollback")
+	}
+}
+
+func TestTransactionInterrupted(t *testing.T) {
+	tx := New(&bytes.Buffer{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran := false
+	err := tx.Run(ctx, Step{Name: "step", Do: func(ctx context.Context) error {
+		ran = true
+		return nil
+	}})
+	if err == nil || err.Error() != "interrupted" || ran {
+		t.Errorf("expected step not to run after interrupt, got %v", err)
+	}
+}